@@ -4,8 +4,11 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"os/exec"
+	"path/filepath"
 	"reload/common"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/fsnotify/fsnotify"
 	"github.com/spf13/cobra"
@@ -13,7 +16,7 @@ import (
 )
 
 var composeCmd = &cobra.Command{
-	Use:   "compose [service]",
+	Use:   "compose [service...]",
 	Short: "Live reload functionality for containerized services built for Docker compose",
 	Run:   composeRun,
 }
@@ -30,37 +33,196 @@ func init() {
 		[]string{},
 		"files/directories to ignore (relative to --path)",
 	)
+	composeCmd.Flags().StringArray(
+		"service",
+		[]string{},
+		"service with its own watch globs, restarted on its own (NAME=GLOB,GLOB...)",
+	)
+	composeCmd.Flags().StringArray(
+		"service-ignore",
+		[]string{},
+		"globs a service ignores (NAME=GLOB,GLOB...)",
+	)
+	composeCmd.Flags().StringArray(
+		"sync",
+		[]string{},
+		"copy changes into a running service instead of restarting it (NAME=LOCAL:CONTAINER)",
+	)
+	composeCmd.Flags().StringArray(
+		"sync-command",
+		[]string{},
+		"command to run in a service after syncing files (NAME=COMMAND)",
+	)
 	// Docker & Docker-Compose flags
+	composeCmd.Flags().String(
+		"engine",
+		"",
+		fmt.Sprintf("Compose engine to use (%s, detected when empty)", strings.Join(common.ComposeEngines, ", ")),
+	)
+	composeCmd.Flags().StringSliceP("file", "f", []string{}, "Compose files to use (docker compose -f)")
+	composeCmd.Flags().String("project-name", "", "Compose project name (docker compose -p)")
+	composeCmd.Flags().StringSlice("profile", []string{}, "Compose profiles to enable (docker compose --profile)")
+	composeCmd.Flags().StringSlice("env-file", []string{}, "Env files for docker compose (docker compose --env-file)")
+	composeCmd.Flags().Bool("build", false, "Rebuild images (docker compose build) before starting & restarting services")
+	composeCmd.Flags().StringArray("build-arg", []string{}, "Build argument for --build (KEY=VALUE)")
+	composeCmd.Flags().Bool("no-cache", false, "Don't use the cache when building images")
+	composeCmd.Flags().String(
+		"on-exit",
+		"",
+		fmt.Sprintf("What to do with the containers when reload exits (%s, default %s)", strings.Join(common.ExitActions, ", "), common.ExitStop),
+	)
+	composeCmd.Flags().Duration(
+		"wait-timeout",
+		common.DefaultWaitTimeout,
+		"How long services get to be running & healthy after (re)starting (0 doesn't wait)",
+	)
 	composeCmd.Flags().BoolP("verbose", "v", true, "Display docker-compose logs to console")
+	composeCmd.Flags().String("save-as", "", "Save the flags as a workflow in reload.toml before starting")
 	rootCmd.AddCommand(composeCmd)
 }
 
-func constructComposeFlags(service string, flags *pflag.FlagSet) common.ComposeFlags {
+func constructComposeFlags(services []string, flags *pflag.FlagSet) common.ComposeFlags {
 	df := common.ComposeFlags{
-		Service: service,
+		Services: map[string]common.ComposeService{},
 	}
 	df.WC.Path, _ = flags.GetString("path")
 	df.WC.Watch, _ = flags.GetStringSlice("watch")
 	df.WC.Ignore, _ = flags.GetStringSlice("ignore")
 	df.Verbose, _ = flags.GetBool("verbose")
+	df.Rebuild, _ = flags.GetBool("build")
+	df.NoCache, _ = flags.GetBool("no-cache")
+	df.Engine, _ = flags.GetString("engine")
+	df.Files, _ = flags.GetStringSlice("file")
+	df.Project, _ = flags.GetString("project-name")
+	df.Profiles, _ = flags.GetStringSlice("profile")
+	df.ComposeEnvFiles, _ = flags.GetStringSlice("env-file")
+	df.OnExit, _ = flags.GetString("on-exit")
+	df.WaitTimeout, _ = flags.GetDuration("wait-timeout")
+
+	df.BuildArgs = map[string]string{}
+	buildArgs, _ := flags.GetStringArray("build-arg")
+	for _, arg := range buildArgs {
+		parts := strings.SplitN(arg, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			common.BasicLogError(fmt.Sprintf("invalid --build-arg %s (expected KEY=VALUE)", arg))
+		}
+		df.BuildArgs[parts[0]] = parts[1]
+	}
+
+	// services passed as arguments reload on any change
+	for _, name := range services {
+		df.Services[name] = common.ComposeService{}
+	}
+	watch, _ := flags.GetStringArray("service")
+	for _, spec := range watch {
+		name, globs := parseServiceFlag("service", spec)
+		svc := df.Services[name]
+		svc.Watch = append(svc.Watch, globs...)
+		df.Services[name] = svc
+	}
+	ignore, _ := flags.GetStringArray("service-ignore")
+	for _, spec := range ignore {
+		name, globs := parseServiceFlag("service-ignore", spec)
+		svc := df.Services[name]
+		svc.Ignore = append(svc.Ignore, globs...)
+		df.Services[name] = svc
+	}
+	syncs, _ := flags.GetStringArray("sync")
+	for _, spec := range syncs {
+		name, paths := parseServiceFlag("sync", spec)
+		svc := df.Services[name]
+		if svc.Sync == nil {
+			svc.Sync = map[string]string{}
+		}
+		for _, path := range paths {
+			parts := strings.SplitN(path, ":", 2)
+			if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+				common.BasicLogError(fmt.Sprintf("invalid --sync %s (expected NAME=LOCAL:CONTAINER)", spec))
+			}
+			svc.Sync[parts[0]] = parts[1]
+		}
+		df.Services[name] = svc
+	}
+	commands, _ := flags.GetStringArray("sync-command")
+	for _, spec := range commands {
+		parts := strings.SplitN(spec, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			common.BasicLogError(fmt.Sprintf("invalid --sync-command %s (expected NAME=COMMAND)", spec))
+		}
+		svc := df.Services[parts[0]]
+		svc.SyncCommand = parts[1]
+		df.Services[parts[0]] = svc
+	}
 
 	return df
 }
 
+// parseServiceFlag splits NAME=GLOB,GLOB into the service name and its globs
+func parseServiceFlag(flag, spec string) (string, []string) {
+	parts := strings.SplitN(spec, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		common.BasicLogError(fmt.Sprintf("invalid --%s %s (expected NAME=GLOB,GLOB...)", flag, spec))
+	}
+
+	globs := []string{}
+	for _, glob := range strings.Split(parts[1], ",") {
+		if glob = strings.TrimSpace(glob); glob != "" {
+			globs = append(globs, glob)
+		}
+	}
+	return parts[0], globs
+}
+
 func composeRun(cmd *cobra.Command, args []string) {
-	var service string = ""
-	if len(args) > 0 {
-		service = args[0]
+	flags := constructComposeFlags(args, cmd.Flags())
+	if name, _ := cmd.Flags().GetString("save-as"); name != "" {
+		saveWorkflow(cmd, composeFlagsTemplate(name, flags))
 	}
-	flags := constructComposeFlags(service, cmd.Flags())
-	err := startComposeReload(flags)
+
+	// clean up the containers on Ctrl-C
+	common.HandleSignals()
+	err := startComposeReload(flags, nil)
 	if err != nil {
 		common.BasicLogError("failed to start live reload")
 		os.Exit(1)
 	}
 }
 
-func startComposeReload(flags common.ComposeFlags) error {
+// startComposeReload watches files and reruns commands until stop is closed (a nil
+// stop channel runs forever)
+func startComposeReload(flags common.ComposeFlags, stop <-chan struct{}) error {
+	if err := flags.WC.Resolve(); err != nil {
+		common.BasicLogError(fmt.Sprintf("unrecognized path %s", flags.WC.Path))
+	}
+
+	// pick the compose engine once, every compose command uses it
+	if flags.Engine == "" {
+		engine, err := common.DetectComposeEngine()
+		if err != nil {
+			common.BasicLogError(err.Error())
+		}
+		flags.Engine = engine
+	} else if !common.IsComposeEngine(flags.Engine) {
+		common.BasicLogError(fmt.Sprintf(
+			"unknown compose engine %s (expected one of %s)",
+			flags.Engine,
+			strings.Join(common.ComposeEngines, ", "),
+		))
+	} else if !common.ComposeEngineWorks(flags.Engine) {
+		common.BasicLogError(fmt.Sprintf("%s is not installed (or not on your PATH)", flags.Engine))
+	}
+	log.Printf("🐳 using %s", common.HiCyan(flags.Engine))
+
+	if flags.OnExit == "" {
+		flags.OnExit = common.ExitStop
+	} else if !common.IsExitAction(flags.OnExit) {
+		common.BasicLogError(fmt.Sprintf(
+			"unknown exit action %s (expected one of %s)",
+			flags.OnExit,
+			strings.Join(common.ExitActions, ", "),
+		))
+	}
+
 	// create a new watcher
 	var w *fsnotify.Watcher
 	w, _ = fsnotify.NewWatcher()
@@ -70,101 +232,308 @@ func startComposeReload(flags common.ComposeFlags) error {
 	flags.WC.Ignore = append(flags.WC.Ignore, ".git")
 	flags.WC.Ignore = append(flags.WC.Ignore, "reload.toml")
 
+	// a narrowed down watch list still has to cover every service's globs
+	if len(flags.WC.Watch) > 0 {
+		for _, name := range composeServices(flags) {
+			for _, glob := range flags.Services[name].Watch {
+				if base := common.GlobBase(glob); base != "." {
+					flags.WC.Watch = append(flags.WC.Watch, base)
+				}
+			}
+		}
+	}
+
 	err := common.AddToFileWatcher(w, &flags.WC)
 	if err != nil {
 		common.BasicLogError("failed to add watchlist to file watcher")
 	}
+	common.WatchEnvFiles(w, &flags.WC, flags.EnvFiles)
 
 	// construct build, run, & clean commands
-	if flags.Service != "" {
-		flags.Run = fmt.Sprintf("docker compose up %s", flags.Service)
-		flags.Clean = fmt.Sprintf("docker compose stop %s", flags.Service)
-	} else {
-		flags.Run = "docker compose up"
-		flags.Clean = fmt.Sprintf("docker compose stop %s", flags.Service)
+	flags.Run, flags.Clean = composeCommands(flags)
+
+	return runComposeReload(w, flags, stop)
+}
+
+// composeCommand builds a compose command for the workflow's engine with its
+// compose files, project name, profiles and env files
+func composeCommand(flags common.ComposeFlags, args ...string) []string {
+	cmd := strings.Fields(flags.Engine)
+	if len(cmd) == 0 {
+		cmd = []string{"docker", "compose"}
+	}
+	for _, file := range flags.Files {
+		cmd = append(cmd, "-f", file)
+	}
+	if flags.Project != "" {
+		cmd = append(cmd, "-p", flags.Project)
 	}
+	for _, profile := range flags.Profiles {
+		cmd = append(cmd, "--profile", profile)
+	}
+	for _, file := range flags.ComposeEnvFiles {
+		cmd = append(cmd, "--env-file", file)
+	}
+	return append(cmd, args...)
+}
 
-	done := make(chan bool)
-	go runComposeReload(w, flags)
+// composeCommands returns the run & clean commands for the workflow's services
+// (or every service). Services run detached, their logs are streamed separately.
+func composeCommands(flags common.ComposeFlags) ([]string, []string) {
+	services := composeServices(flags)
+	return composeCommand(flags, append([]string{"up", "-d"}, services...)...),
+		composeCommand(flags, append([]string{"stop"}, services...)...)
+}
 
-	// used to synchronize between the goroutine thread and the main thread
-	// we're forcing the main thread to wait until there is some data to be consumed in the done channel
-	<-done
-	return nil
+// composeServices returns the names of the services a workflow runs, in order
+func composeServices(flags common.ComposeFlags) []string {
+	names := make([]string, 0, len(flags.Services))
+	for name := range flags.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
 }
 
-func runComposeReload(watcher *fsnotify.Watcher, flags common.ComposeFlags) error {
+// affectedServices returns the services watching a changed file, nil when
+// every service is restarted together. ok is false when no service cares.
+func affectedServices(flags common.ComposeFlags, path string, isEnvFile bool) ([]string, bool) {
+	if len(flags.Services) == 0 {
+		return nil, true
+	}
+
+	services := composeServices(flags)
+	rel, err := filepath.Rel(flags.WC.Path, path)
+	if isEnvFile || err != nil || strings.HasPrefix(rel, "..") {
+		// env files (and files outside --path) are shared by every service
+		return services, true
+	}
+
+	affected := []string{}
+	for _, name := range services {
+		svc := flags.Services[name]
+		// synced paths are always watched
+		watch := append([]string{}, svc.Watch...)
+		for local := range svc.Sync {
+			watch = append(watch, local)
+		}
+		if len(svc.Watch) > 0 && !common.MatchesAny(rel, watch) {
+			continue
+		}
+		if common.MatchesAny(rel, svc.Ignore) {
+			continue
+		}
+		affected = append(affected, name)
+	}
+	return affected, len(affected) > 0
+}
+
+func runComposeReload(watcher *fsnotify.Watcher, flags common.ComposeFlags, stop <-chan struct{}) error {
+	// the exit action runs on Ctrl-C and fatal errors (a failed build included),
+	// but not when the workflow is only restarted
+	logs := newComposeLogs(flags)
+	removeHook := common.OnExit(func() { exitCompose(flags, logs) })
+	defer removeHook()
+
 	// run initial build
-	proc := runComposeCommands(flags, nil)
-	for {
-		select {
-		case event, ok := <-watcher.Events:
-			if !ok {
-				common.BasicLogError("failed to read from watcher.Events channel")
-			}
-			if !common.IsExcluded(event.Name, flags.WC.Ignore) {
-				// listen for file changes
-				if event.Op&fsnotify.Create == fsnotify.Create {
-					common.LogEvent("a wild %s has appeared", event.Name)
-					flags.WC.Watch = append(flags.WC.Watch, event.Name)
-					common.AddToFileWatcher(watcher, &flags.WC)
-				} else if event.Op&fsnotify.Remove == fsnotify.Remove {
-					common.LogEvent("%s has been removed", event.Name)
-					// todo: handle removing from file watcher
-				} else if event.Op&fsnotify.Rename == fsnotify.Rename {
-					common.LogEvent("%s has a new name", event.Name)
-				} else if event.Op&fsnotify.Write == fsnotify.Write {
-					common.LogEvent("%s has changed", event.Name)
-				}
-				// rerun commands after changes
-				if event.Op&fsnotify.Chmod != fsnotify.Chmod {
-					proc = runComposeCommands(flags, proc)
-				}
+	runComposeCommands(flags, logs)
+
+	// files that changed in each service since the last reload
+	pending := map[string][]string{}
+	// services that weren't healthy after the last reload
+	failed := []string{}
+	loop := &common.Loop{
+		Watcher:  watcher,
+		WC:       &flags.WC,
+		EnvFiles: flags.EnvFiles,
+		Track: func(name string, isEnvFile bool) bool {
+			services, ok := affectedServices(flags, name, isEnvFile)
+			for _, service := range services {
+				pending[service] = append(pending[service], name)
 			}
-		case err, ok := <-watcher.Errors:
-			if !ok {
-				common.BasicLogError("failed to read from watcher.Errors channel")
+			return ok || len(failed) > 0
+		},
+		Reload: func() {
+			changes := pending
+			pending = map[string][]string{}
+			// failed services are restarted on the next change, whatever it is
+			for _, name := range failed {
+				changes[name] = nil
 			}
-			common.BasicLogError(fmt.Sprintf("%+v", err))
+			failed = reloadCompose(flags, logs, changes)
+		},
+		Stop: func() { stopComposeProcess(flags, logs) },
+	}
+	loop.Run(stop)
+	return nil
+}
+
+// reloadCompose syncs or restarts the services with changed files, or
+// restarts the whole project when the workflow doesn't list any services.
+// Only the restarted containers are replaced, the others keep running. A
+// service without changed files (nil) is always restarted. It returns the
+// services that aren't healthy after restarting.
+func reloadCompose(flags common.ComposeFlags, logs *composeLogs, changes map[string][]string) []string {
+	// env files are read again on every run to pick up changes
+	env := common.Environ(flags.WC.Path, flags.Env, flags.EnvFiles)
+
+	services := []string{}
+	for _, name := range composeServices(flags) {
+		if files, ok := changes[name]; ok && (files == nil || !syncService(flags, env, name, files)) {
+			services = append(services, name)
 		}
 	}
+	if len(flags.Services) > 0 && len(services) == 0 {
+		return nil
+	}
+
+	buildComposeImages(flags, env, services)
+
+	args := []string{"up", "-d", "--force-recreate"}
+	if len(services) > 0 {
+		log.Printf("🔁 %s %s", common.HiGreen("restarting"), common.HiCyan(strings.Join(services, ", ")))
+		args = append(append(args, "--no-deps"), services...)
+	} else {
+		log.Printf("🔁 %s", common.HiGreen("restarting every service"))
+	}
+
+	since := time.Now()
+	if err := runner.Run(composeCommand(flags, args...), flags.WC.Path, env, flags.Verbose); err != nil {
+		common.LogError("failed to restart containers")
+	}
+	logs.follow(services, since)
+	failed, err := waitForServices(flags, env, services)
+	if err != nil {
+		common.LogError(fmt.Sprintf("reload failed, %s (retrying on the next change)", err))
+	}
+	return failed
 }
 
-func runComposeCommands(flags common.ComposeFlags, oldRunProc *exec.Cmd) *exec.Cmd {
-	// setup (change dir)
-	// change current working directory
-	if err := os.Chdir(flags.WC.Path); err != nil {
-		common.BasicLogError(fmt.Sprintf("unrecognized path %s", flags.WC.Path))
+// runComposeCommands builds & starts the services in the background and
+// starts streaming their logs
+func runComposeCommands(flags common.ComposeFlags, logs *composeLogs) {
+	env := common.Environ(flags.WC.Path, flags.Env, flags.EnvFiles)
+	buildComposeImages(flags, env, composeServices(flags))
+
+	log.Printf("🏃 %s", common.HiGreen("running..."))
+	since := time.Now()
+	if err := runner.Run(flags.Run, flags.WC.Path, env, flags.Verbose); err != nil {
+		common.BasicLogError("failed to execute run process")
+	}
+	logs.follow(nil, since)
+	// unhealthy services fail the start like a failed build
+	if _, err := waitForServices(flags, env, composeServices(flags)); err != nil {
+		common.BasicLogError(fmt.Sprintf("failed to start the services, %s", err))
 	}
+}
 
-	// cleanup
-	if oldRunProc != nil {
-		oldRunProc.Process.Kill()
-		if err := oldRunProc.Wait(); err != nil {
-			log.Printf(
-				"%s\tcleaned up after old run process",
-				common.HiCyan("[INFO]"),
-			)
-		}
+// buildComposeImages rebuilds the images of some services (or every service).
+// A failed build stops reload, just like a failed build step of a basic workflow.
+func buildComposeImages(flags common.ComposeFlags, env []string, services []string) {
+	if !flags.Rebuild {
+		return
+	}
 
-		// remove the docker containers
-		_, err := common.StartProcess(flags.Clean, flags.WC.Path, true, false)
-		if err != nil {
-			common.BasicLogError("failed to clean up containers")
-		}
+	args := composeCommand(flags, "build")
+	if flags.NoCache {
+		args = append(args, "--no-cache")
 	}
+	keys := make([]string, 0, len(flags.BuildArgs))
+	for key := range flags.BuildArgs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		args = append(args, "--build-arg", fmt.Sprintf("%s=%s", key, flags.BuildArgs[key]))
+	}
+	args = append(args, services...)
 
-	var runProc *exec.Cmd
-	var err error
-	if flags.Run != "" {
-		// execute run cmd
-		log.Printf("🏃 %s", common.HiGreen("running..."))
-		runProc, err = common.StartProcess(flags.Run, flags.WC.Path, false, flags.Verbose)
-		if err != nil {
-			common.BasicLogError("failed to execute run process")
+	log.Printf("🏗️  %s", common.HiYlw("building..."))
+	if err := runner.Run(args, flags.WC.Path, env, flags.Verbose); err != nil {
+		common.BasicLogError("failed to execute build process")
+	}
+}
+
+// stopComposeProcess stops streaming logs and stops the containers
+func stopComposeProcess(flags common.ComposeFlags, logs *composeLogs) {
+	logs.stop()
+
+	env := common.Environ(flags.WC.Path, flags.Env, flags.EnvFiles)
+	if err := runner.Run(flags.Clean, flags.WC.Path, env, false); err != nil {
+		common.LogError("failed to clean up containers")
+	}
+}
+
+// exitCompose stops streaming logs and runs the workflow's exit action, then
+// checks the containers are really stopped (or removed)
+func exitCompose(flags common.ComposeFlags, logs *composeLogs) {
+	logs.stop()
+
+	services := composeServices(flags)
+	var args, check []string
+	switch flags.OnExit {
+	case common.ExitNone:
+		log.Printf("🐳 %s", common.HiYlw("leaving the containers running"))
+		return
+	case common.ExitDown:
+		// down takes the whole project, services or not
+		args = []string{"down"}
+		check = []string{"ps", "-a", "-q"}
+	case common.ExitDownVolumes:
+		args = []string{"down", "-v"}
+		check = []string{"ps", "-a", "-q"}
+	default:
+		args = append([]string{"stop"}, services...)
+		check = append([]string{"ps", "-q"}, services...)
+	}
+
+	log.Printf("🧹 %s", common.HiYlw(strings.Join(args, " ")+"..."))
+	env := common.Environ(flags.WC.Path, flags.Env, flags.EnvFiles)
+	if err := runner.Run(composeCommand(flags, args...), flags.WC.Path, env, flags.Verbose); err != nil {
+		common.LogError(fmt.Sprintf("failed to %s the containers", args[0]))
+		return
+	}
+
+	out, err := runner.Output(composeCommand(flags, check...), flags.WC.Path, env)
+	if err != nil {
+		common.LogWarning("could not check the state of the containers")
+		return
+	}
+	if left := strings.Fields(string(out)); len(left) > 0 {
+		state := "running"
+		if flags.OnExit != common.ExitStop {
+			state = "left behind"
 		}
+		common.LogWarning(fmt.Sprintf("%d container(s) still %s", len(left), state))
+		return
 	}
+	log.Printf("✅ %s", common.HiGreen("containers cleaned up"))
+}
 
-	// return the new proc
-	return runProc
+// composeFlagsTemplate converts the compose flags into a reload.toml workflow
+func composeFlagsTemplate(name string, flags common.ComposeFlags) initTemplate {
+	waitTimeout := ""
+	if flags.WaitTimeout != common.DefaultWaitTimeout {
+		waitTimeout = flags.WaitTimeout.String()
+	}
+
+	return initTemplate{
+		name:          name,
+		containerized: true,
+		verbose:       flags.Verbose,
+		path:          flags.WC.Path,
+		watch:         flags.WC.Watch,
+		ignore:        flags.WC.Ignore,
+		services:      flags.Services,
+		engine:        flags.Engine,
+		files:         flags.Files,
+		project:       flags.Project,
+		profiles:      flags.Profiles,
+		envFiles:      flags.ComposeEnvFiles,
+		rebuild:       flags.Rebuild,
+		buildArgs:     flags.BuildArgs,
+		noCache:       flags.NoCache,
+		onExit:        flags.OnExit,
+		waitTimeout:   waitTimeout,
+	}
 }
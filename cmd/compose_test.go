@@ -0,0 +1,409 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"reload/common"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// fakeRunner records compose commands instead of running them
+type fakeRunner struct {
+	mu    sync.Mutex
+	calls []string
+	// what Output prints for the commands containing a key (like "ps -a"),
+	// one output per call with the last one repeated
+	outputs map[string][]string
+	// commands containing one of these fail
+	fail []string
+}
+
+func (f *fakeRunner) record(cmd []string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	args := append([]string{}, cmd...)
+	for i := 1; i < len(args); i++ {
+		// log timestamps change on every run
+		if args[i-1] == "--since" {
+			args[i] = "<since>"
+		}
+	}
+	call := strings.Join(args, " ")
+	f.calls = append(f.calls, call)
+	return call
+}
+
+func (f *fakeRunner) failed(call string) bool {
+	for _, key := range f.fail {
+		if strings.Contains(call, key) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *fakeRunner) Run(cmd []string, dir string, env []string, verbose bool) error {
+	if call := f.record(cmd); f.failed(call) {
+		return os.ErrInvalid
+	}
+	return nil
+}
+
+func (f *fakeRunner) Output(cmd []string, dir string, env []string) ([]byte, error) {
+	call := f.record(cmd)
+	if f.failed(call) {
+		return nil, os.ErrInvalid
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for key, outs := range f.outputs {
+		if strings.Contains(call, key) && len(outs) > 0 {
+			if len(outs) > 1 {
+				f.outputs[key] = outs[1:]
+			}
+			return []byte(outs[0]), nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *fakeRunner) Start(cmd []string, dir string, env []string) (composeProcess, error) {
+	f.record(cmd)
+	return fakeProcess{}, nil
+}
+
+type fakeProcess struct{}
+
+func (fakeProcess) Kill() {}
+func (fakeProcess) Stop() {}
+
+// testComposeFlags is a compose workflow in a temporary directory
+func testComposeFlags(t *testing.T, services map[string]common.ComposeService) common.ComposeFlags {
+	return common.ComposeFlags{
+		WC:       common.WatcherConfig{Path: t.TempDir()},
+		Services: services,
+		Engine:   "docker compose",
+		OnExit:   common.ExitStop,
+	}
+}
+
+// driveComposeReload runs the compose reload loop with the fake runner, sends
+// it file events and stops it, returning every compose command it ran
+func driveComposeReload(t *testing.T, fake *fakeRunner, flags common.ComposeFlags, events ...fsnotify.Event) []string {
+	t.Helper()
+
+	prev := runner
+	runner = fake
+	defer func() { runner = prev }()
+
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	flags.Run, flags.Clean = composeCommands(flags)
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		runComposeReload(w, flags, stop)
+		close(done)
+	}()
+
+	// events are unbuffered, so each one is handled before the next is sent
+	for _, event := range events {
+		w.Events <- event
+	}
+	close(stop)
+	<-done
+
+	return fake.calls
+}
+
+func writeEvent(flags common.ComposeFlags, file string) fsnotify.Event {
+	return fsnotify.Event{Name: filepath.Join(flags.WC.Path, file), Op: fsnotify.Write}
+}
+
+func assertCalls(t *testing.T, got, want []string) {
+	t.Helper()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("compose commands:\n got: %q\nwant: %q", got, want)
+	}
+}
+
+func TestComposeStartsAndStopsServices(t *testing.T) {
+	flags := testComposeFlags(t, map[string]common.ComposeService{"web": {}, "db": {}})
+
+	calls := driveComposeReload(t, &fakeRunner{}, flags)
+	assertCalls(t, calls, []string{
+		"docker compose up -d db web",
+		"docker compose stop db web",
+	})
+}
+
+func TestComposeRestartsEveryServiceWithoutServices(t *testing.T) {
+	flags := testComposeFlags(t, nil)
+
+	calls := driveComposeReload(t, &fakeRunner{}, flags, writeEvent(flags, "main.go"))
+	assertCalls(t, calls, []string{
+		"docker compose up -d",
+		"docker compose up -d --force-recreate",
+		"docker compose stop",
+	})
+}
+
+func TestComposeRestartsOnlyAffectedServices(t *testing.T) {
+	flags := testComposeFlags(t, map[string]common.ComposeService{
+		"web": {Watch: []string{"web/**"}, Ignore: []string{"web/*_test.js"}},
+		"api": {Watch: []string{"api"}},
+		"db":  {},
+	})
+	flags.Rebuild = true
+
+	calls := driveComposeReload(t, &fakeRunner{}, flags,
+		writeEvent(flags, "web/index.js"),
+		writeEvent(flags, "web/index_test.js"),
+		writeEvent(flags, "api/main.go"),
+		writeEvent(flags, "go.mod"),
+	)
+	assertCalls(t, calls, []string{
+		"docker compose build api db web",
+		"docker compose up -d api db web",
+		// web/index.js restarts web and db (which reloads on any change)
+		"docker compose build db web",
+		"docker compose up -d --force-recreate --no-deps db web",
+		// web/index_test.js is ignored by web
+		"docker compose build db",
+		"docker compose up -d --force-recreate --no-deps db",
+		"docker compose build api db",
+		"docker compose up -d --force-recreate --no-deps api db",
+		"docker compose build db",
+		"docker compose up -d --force-recreate --no-deps db",
+		"docker compose stop api db web",
+	})
+}
+
+func TestComposeSkipsChangesNoServiceWatches(t *testing.T) {
+	flags := testComposeFlags(t, map[string]common.ComposeService{
+		"web": {Watch: []string{"web"}},
+	})
+
+	calls := driveComposeReload(t, &fakeRunner{}, flags, writeEvent(flags, "docs/guide.txt"))
+	assertCalls(t, calls, []string{
+		"docker compose up -d web",
+		"docker compose stop web",
+	})
+}
+
+func TestComposePassesBuildAndGlobalOptions(t *testing.T) {
+	flags := testComposeFlags(t, map[string]common.ComposeService{"web": {}})
+	flags.Files = []string{"compose.yaml", "compose.dev.yaml"}
+	flags.Project = "app"
+	flags.Profiles = []string{"debug"}
+	flags.Rebuild = true
+	flags.NoCache = true
+	flags.BuildArgs = map[string]string{"VERSION": "dev", "A": "1 2"}
+
+	calls := driveComposeReload(t, &fakeRunner{}, flags, writeEvent(flags, "main.go"))
+	options := "docker compose -f compose.yaml -f compose.dev.yaml -p app --profile debug"
+	assertCalls(t, calls, []string{
+		options + " build --no-cache --build-arg A=1 2 --build-arg VERSION=dev web",
+		options + " up -d web",
+		options + " build --no-cache --build-arg A=1 2 --build-arg VERSION=dev web",
+		options + " up -d --force-recreate --no-deps web",
+		options + " stop web",
+	})
+}
+
+func TestComposeSyncsFilesInsteadOfRestarting(t *testing.T) {
+	flags := testComposeFlags(t, map[string]common.ComposeService{
+		"web": {
+			Watch:       []string{"web/package.json"},
+			Sync:        map[string]string{"web/src": "/app/src"},
+			SyncCommand: "kill -HUP 1",
+		},
+	})
+	src := filepath.Join(flags.WC.Path, "web", "src")
+	if err := os.MkdirAll(src, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(src, "app.js"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := driveComposeReload(t, &fakeRunner{}, flags,
+		writeEvent(flags, "web/src/app.js"),
+		// deleted files are removed from the container
+		fsnotify.Event{Name: filepath.Join(src, "old.js"), Op: fsnotify.Remove},
+		// files outside the synced paths still restart the service
+		writeEvent(flags, "web/package.json"),
+	)
+	assertCalls(t, calls, []string{
+		"docker compose up -d web",
+		"docker compose cp " + filepath.Join(src, "app.js") + " web:/app/src/app.js",
+		"docker compose exec -T web sh -c kill -HUP 1",
+		"docker compose exec -T web rm -rf /app/src/old.js",
+		"docker compose exec -T web sh -c kill -HUP 1",
+		"docker compose up -d --force-recreate --no-deps web",
+		"docker compose stop web",
+	})
+}
+
+func TestComposeRestartsWhenSyncFails(t *testing.T) {
+	flags := testComposeFlags(t, map[string]common.ComposeService{
+		"web": {Sync: map[string]string{"src": "/app/src"}},
+	})
+	if err := os.MkdirAll(filepath.Join(flags.WC.Path, "src"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(flags.WC.Path, "src", "app.js"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fake := &fakeRunner{fail: []string{" cp "}}
+	calls := driveComposeReload(t, fake, flags, writeEvent(flags, "src/app.js"))
+	assertCalls(t, calls, []string{
+		"docker compose up -d web",
+		"docker compose cp " + filepath.Join(flags.WC.Path, "src", "app.js") + " web:/app/src/app.js",
+		"docker compose up -d --force-recreate --no-deps web",
+		"docker compose stop web",
+	})
+}
+
+func TestComposeFollowsLogsOfRestartedServices(t *testing.T) {
+	flags := testComposeFlags(t, map[string]common.ComposeService{
+		"web": {Watch: []string{"web"}},
+		"api": {Watch: []string{"api"}},
+	})
+	flags.Verbose = true
+
+	calls := driveComposeReload(t, &fakeRunner{}, flags, writeEvent(flags, "api/main.go"))
+	assertCalls(t, calls, []string{
+		"docker compose up -d api web",
+		"docker compose logs -f --since <since> api",
+		"docker compose logs -f --since <since> web",
+		"docker compose up -d --force-recreate --no-deps api",
+		"docker compose logs -f --since <since> api",
+		"docker compose stop api web",
+	})
+}
+
+func TestComposeWaitsForHealthyServices(t *testing.T) {
+	flags := testComposeFlags(t, map[string]common.ComposeService{"web": {}})
+	flags.WaitTimeout = common.DefaultWaitTimeout
+
+	fake := &fakeRunner{outputs: map[string][]string{
+		"ps -a --format json": {`[{"Service":"web","State":"running","Health":"healthy"}]`},
+	}}
+	calls := driveComposeReload(t, fake, flags, writeEvent(flags, "main.go"))
+	assertCalls(t, calls, []string{
+		"docker compose up -d web",
+		"docker compose ps -a --format json web",
+		"docker compose up -d --force-recreate --no-deps web",
+		"docker compose ps -a --format json web",
+		"docker compose stop web",
+	})
+}
+
+func TestComposeRetriesUnhealthyServices(t *testing.T) {
+	flags := testComposeFlags(t, map[string]common.ComposeService{
+		"web": {Watch: []string{"web"}},
+		"api": {Watch: []string{"api"}},
+	})
+	flags.WaitTimeout = common.DefaultWaitTimeout
+
+	healthy := `{"Service":"api","State":"running","Health":"healthy"}
+{"Service":"web","State":"running","Health":"healthy"}`
+	fake := &fakeRunner{outputs: map[string][]string{
+		"ps -a --format json": {
+			healthy,
+			`{"Service":"web","State":"running","Health":"unhealthy"}`,
+			healthy,
+		},
+	}}
+	calls := driveComposeReload(t, fake, flags,
+		writeEvent(flags, "web/index.js"),
+		// web is unhealthy, so it's restarted with api even though it doesn't watch api
+		writeEvent(flags, "api/main.go"),
+		// web is healthy again
+		writeEvent(flags, "api/main.go"),
+	)
+	assertCalls(t, calls, []string{
+		"docker compose up -d api web",
+		"docker compose ps -a --format json api web",
+		"docker compose up -d --force-recreate --no-deps web",
+		"docker compose ps -a --format json web",
+		"docker compose up -d --force-recreate --no-deps api web",
+		"docker compose ps -a --format json api web",
+		"docker compose up -d --force-recreate --no-deps api",
+		"docker compose ps -a --format json api",
+		"docker compose stop api web",
+	})
+}
+
+func TestWaitForServices(t *testing.T) {
+	tests := []struct {
+		name string
+		ps   string
+		want []string
+	}{
+		{"healthy", `[{"Service":"web","State":"running","Health":"healthy"}]`, nil},
+		{"no healthcheck", `{"Service":"web","State":"running"}`, nil},
+		{"unhealthy", `{"Service":"web","State":"running","Health":"unhealthy"}`, []string{"web"}},
+		{"crashed", `{"Service":"web","State":"exited","ExitCode":1}`, []string{"web"}},
+		{"one-off", `{"Service":"web","State":"exited","ExitCode":0}`, nil},
+		// engines that only report container names
+		{"no service", `{"Name":"app-web-1","State":"running"}`, nil},
+		{"no service crashed", `{"Name":"app-web-1","State":"exited","ExitCode":1}`, []string{"app-web-1"}},
+		// engines without --format json don't hold up reloads
+		{"unsupported", `NAME  IMAGE  SERVICE`, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prev := runner
+			runner = &fakeRunner{outputs: map[string][]string{"ps": {tt.ps}}}
+			defer func() { runner = prev }()
+
+			flags := testComposeFlags(t, nil)
+			flags.WaitTimeout = common.DefaultWaitTimeout
+			got, err := waitForServices(flags, nil, []string{"web"})
+			if !reflect.DeepEqual(got, tt.want) || (err != nil) != (tt.want != nil) {
+				t.Errorf("waitForServices() = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestExitCompose(t *testing.T) {
+	tests := []struct {
+		action string
+		want   []string
+	}{
+		{common.ExitStop, []string{"docker compose stop web", "docker compose ps -q web"}},
+		{common.ExitDown, []string{"docker compose down", "docker compose ps -a -q"}},
+		{common.ExitDownVolumes, []string{"docker compose down -v", "docker compose ps -a -q"}},
+		{common.ExitNone, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.action, func(t *testing.T) {
+			fake := &fakeRunner{}
+			prev := runner
+			runner = fake
+			defer func() { runner = prev }()
+
+			flags := testComposeFlags(t, map[string]common.ComposeService{"web": {}})
+			flags.OnExit = tt.action
+			exitCompose(flags, newComposeLogs(flags))
+			assertCalls(t, fake.calls, tt.want)
+		})
+	}
+}
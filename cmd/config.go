@@ -0,0 +1,208 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"reload/common"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect, validate and convert the config file without starting any watchers",
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check every workflow in reload.toml (paths, commands, ignore globs)",
+	Args:  cobra.NoArgs,
+	Run:   configValidateRun,
+}
+
+var configConvertCmd = &cobra.Command{
+	Use:   "convert",
+	Short: "Convert the config file to another format (toml, yaml or json)",
+	Args:  cobra.NoArgs,
+	Run:   configConvertRun,
+}
+
+var configShowCmd = &cobra.Command{
+	Use:               "show workflow",
+	Short:             "Print a workflow with all of its defaults filled in",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeWorkflow,
+	Run:               configShowRun,
+}
+
+func init() {
+	configShowCmd.Flags().StringP("format", "f", "toml", "Output format (toml, yaml or json)")
+	configShowCmd.Flags().String("profile", "", "Show the workflow with a profile applied")
+	configShowCmd.RegisterFlagCompletionFunc("profile", completeProfiles)
+	configConvertCmd.Flags().String("to", "", "Format to convert to (toml, yaml, yml or json)")
+	configConvertCmd.Flags().StringP("output", "o", "", "File to write (default: the config file with the new extension, - for stdout)")
+	configConvertCmd.Flags().Bool("force", false, "Overwrite the output file if it exists")
+	configConvertCmd.MarkFlagRequired("to")
+	configCmd.AddCommand(configValidateCmd)
+	configCmd.AddCommand(configShowCmd)
+	configCmd.AddCommand(configConvertCmd)
+	rootCmd.AddCommand(configCmd)
+}
+
+// readConfig loads the --config file, or the nearest reload.toml in --path
+// (or its parents), exiting with a helpful message when there isn't one
+func readConfig(cmd *cobra.Command) *common.Config {
+	file, err := configFile(cmd)
+	if err != nil {
+		log.Printf(
+			"%s\tcould not find a reload.toml file",
+			common.ErrorRed("error"),
+		)
+		log.Fatalf(
+			"%s\trun %s to create one",
+			common.ExtraHiYlw("tip"),
+			common.ExtraHiGreen("reload init"),
+		)
+	}
+
+	// read file
+	conf, err := common.LoadConfig(file)
+	if errors.Is(err, os.ErrNotExist) {
+		common.BasicLogError(fmt.Sprintf("could not read %s", file))
+	} else if err != nil {
+		common.BasicLogError(fmt.Sprintf("%s: %+v", file, err))
+	}
+	for _, warning := range conf.Warnings {
+		common.LogWarning(fmt.Sprintf("%s: %s", file, warning))
+	}
+
+	return conf
+}
+
+// configFile returns the --config flag, or searches for reload.toml
+// starting from --path
+func configFile(cmd *cobra.Command) (string, error) {
+	if file, _ := cmd.Flags().GetString("config"); file != "" {
+		return file, nil
+	}
+
+	path, _ := cmd.Flags().GetString("path")
+	return common.FindConfig(path)
+}
+
+func configValidateRun(cmd *cobra.Command, _ []string) {
+	conf := readConfig(cmd)
+
+	failed := false
+	for _, name := range conf.Names() {
+		// every profile has to be runnable too
+		for _, profile := range append([]string{""}, conf.Workflows[name].ProfileNames()...) {
+			label := name
+			if profile != "" {
+				label = fmt.Sprintf("%s (profile %s)", name, profile)
+			}
+
+			workflow, err := conf.Workflow(name, profile)
+			if err != nil {
+				log.Printf("%s\t%s: %s: %s", common.ErrorRed("error"), conf.File, label, err)
+				failed = true
+				continue
+			}
+			errs := workflow.Check()
+			for _, err := range errs {
+				log.Printf("%s\t%s: %s: %s", common.ErrorRed("error"), conf.File, label, err)
+			}
+			if len(errs) > 0 {
+				failed = true
+				continue
+			}
+			log.Printf("✅ %s", common.HiCyan(label))
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+	log.Printf("%s is valid", common.HiCyan(conf.File))
+}
+
+func configShowRun(cmd *cobra.Command, args []string) {
+	conf := readConfig(cmd)
+	format, _ := cmd.Flags().GetString("format")
+
+	profile, _ := cmd.Flags().GetString("profile")
+
+	workflow, err := conf.Workflow(args[0], profile)
+	if err != nil {
+		common.BasicLogError(fmt.Sprintf("%s: %+v", conf.File, err))
+	}
+
+	// profiles are listed by `reload list`, only the resolved keys are printed
+	shown := *workflow
+	shown.Profiles = nil
+	out := map[string]*common.Workflow{workflow.Name: &shown}
+	if err := common.EncodeRaw(os.Stdout, out, format); err != nil {
+		common.BasicLogError(fmt.Sprintf("failed to print workflow: %+v", err))
+	}
+}
+
+func configConvertRun(cmd *cobra.Command, _ []string) {
+	to, _ := cmd.Flags().GetString("to")
+	output, _ := cmd.Flags().GetString("output")
+	force, _ := cmd.Flags().GetBool("force")
+
+	// --to is an extension (yml works too), which is kept for the new file
+	format, err := common.ConfigFormat("reload." + to)
+	if err != nil {
+		common.BasicLogError(fmt.Sprintf("unknown format %s (expected toml, yaml, yml or json)", to))
+	}
+
+	// only valid configs are converted
+	conf := readConfig(cmd)
+	from, _ := common.ConfigFormat(conf.File)
+	if output == "" {
+		output = strings.TrimSuffix(conf.File, filepath.Ext(conf.File)) + "." + strings.ToLower(to)
+	}
+
+	// the file is converted as written (defaults, extends & variables are kept)
+	data, err := ioutil.ReadFile(conf.File)
+	if err != nil {
+		common.BasicLogError(fmt.Sprintf("failed to read %s", conf.File))
+	}
+	raw, err := common.DecodeRaw(data, from)
+	if err != nil {
+		common.BasicLogError(fmt.Sprintf("failed to read %s: %+v", conf.File, err))
+	}
+
+	var buf bytes.Buffer
+	if err := common.EncodeRaw(&buf, raw, format); err != nil {
+		common.BasicLogError(fmt.Sprintf("failed to convert %s: %+v", conf.File, err))
+	}
+
+	if output == "-" {
+		os.Stdout.Write(buf.Bytes())
+		return
+	}
+	if _, err := os.Stat(output); err == nil && !force {
+		common.BasicLogError(fmt.Sprintf("%s already exists (use --force to overwrite it)", output))
+	}
+	if err := ioutil.WriteFile(output, buf.Bytes(), 0644); err != nil {
+		common.BasicLogError(fmt.Sprintf("failed to write %s", output))
+	}
+
+	log.Printf("%s created from %s", common.HiCyan(output), common.HiCyan(conf.File))
+	if from == common.FormatTOML {
+		common.LogWarning("comments are not carried over to the new file")
+	}
+	log.Printf(
+		"%s\tremove %s so reload uses the new file",
+		common.ExtraHiYlw("tip"),
+		conf.File,
+	)
+}
@@ -0,0 +1,358 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reload/common"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+const (
+	// labels put on every container reload starts, with the workflow name and
+	// the absolute path of the project (workflows in different directories
+	// can share a name)
+	containerLabel = "reload.workflow"
+	pathLabel      = "reload.path"
+)
+
+var dockerCmd = &cobra.Command{
+	Use:   "docker",
+	Short: "Live reload a single container built from a Dockerfile (no compose file needed)",
+	Args:  cobra.NoArgs,
+	Run:   dockerRun,
+}
+
+func init() {
+	dockerCmd.Flags().StringSliceP(
+		"watch",
+		"w",
+		[]string{},
+		"files/directories to watch (relative to --path)",
+	)
+	dockerCmd.Flags().StringSlice(
+		"ignore",
+		[]string{},
+		"files/directories to ignore (relative to --path)",
+	)
+	dockerCmd.Flags().String("dockerfile", "Dockerfile", "Dockerfile to build (relative to --path)")
+	dockerCmd.Flags().String("image", "", "Image to tag the build with (default: reload-<directory>:dev)")
+	dockerCmd.Flags().String("name", "", "Container name (default: reload-<directory>)")
+	dockerCmd.Flags().StringSlice("publish", []string{}, "Ports to publish (HOST:CONTAINER)")
+	dockerCmd.Flags().StringSlice("volume", []string{}, "Volumes to mount (SOURCE:TARGET, relative sources are relative to --path)")
+	dockerCmd.Flags().StringArrayP("env", "e", []string{}, "Environment variable for the container (KEY=VALUE)")
+	dockerCmd.Flags().StringSlice("env-file", []string{}, ".env files to load into the container")
+	dockerCmd.Flags().StringArray("build-arg", []string{}, "Build argument (KEY=VALUE)")
+	dockerCmd.Flags().Bool("no-cache", false, "Don't use the cache when building the image")
+	dockerCmd.Flags().String(
+		"engine",
+		"",
+		fmt.Sprintf("Container engine to use (%s, detected when empty)", strings.Join(common.ContainerEngines, ", ")),
+	)
+	dockerCmd.Flags().BoolP("verbose", "v", true, "Display build output and container logs to console")
+	dockerCmd.Flags().String("save-as", "", "Save the flags as a workflow in reload.toml before starting")
+	rootCmd.AddCommand(dockerCmd)
+}
+
+func constructDockerFlags(flags *pflag.FlagSet) common.DockerFlags {
+	df := common.DockerFlags{
+		Env:       map[string]string{},
+		BuildArgs: map[string]string{},
+	}
+	df.WC.Path, _ = flags.GetString("path")
+	df.WC.Watch, _ = flags.GetStringSlice("watch")
+	df.WC.Ignore, _ = flags.GetStringSlice("ignore")
+	df.Dockerfile, _ = flags.GetString("dockerfile")
+	df.Image, _ = flags.GetString("image")
+	df.Container, _ = flags.GetString("name")
+	df.Ports, _ = flags.GetStringSlice("publish")
+	df.Volumes, _ = flags.GetStringSlice("volume")
+	df.EnvFiles, _ = flags.GetStringSlice("env-file")
+	df.NoCache, _ = flags.GetBool("no-cache")
+	df.Engine, _ = flags.GetString("engine")
+	df.Verbose, _ = flags.GetBool("verbose")
+
+	if path, err := filepath.Abs(df.WC.Path); err == nil {
+		df.Name = filepath.Base(path)
+	}
+
+	env, _ := flags.GetStringArray("env")
+	for _, pair := range env {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			common.BasicLogError(fmt.Sprintf("invalid --env %s (expected KEY=VALUE)", pair))
+		}
+		df.Env[parts[0]] = parts[1]
+	}
+	buildArgs, _ := flags.GetStringArray("build-arg")
+	for _, arg := range buildArgs {
+		parts := strings.SplitN(arg, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			common.BasicLogError(fmt.Sprintf("invalid --build-arg %s (expected KEY=VALUE)", arg))
+		}
+		df.BuildArgs[parts[0]] = parts[1]
+	}
+
+	return df
+}
+
+func dockerRun(cmd *cobra.Command, _ []string) {
+	flags := constructDockerFlags(cmd.Flags())
+	if name, _ := cmd.Flags().GetString("save-as"); name != "" {
+		saveWorkflow(cmd, dockerFlagsTemplate(name, flags))
+	}
+
+	// remove the container on Ctrl-C
+	common.HandleSignals()
+	err := startDockerReload(flags, nil)
+	if err != nil {
+		common.BasicLogError("failed to start live reload")
+		os.Exit(1)
+	}
+}
+
+// startDockerReload watches files and rebuilds & replaces the container until
+// stop is closed (a nil stop channel runs forever)
+func startDockerReload(flags common.DockerFlags, stop <-chan struct{}) error {
+	if err := flags.WC.Resolve(); err != nil {
+		common.BasicLogError(fmt.Sprintf("unrecognized path %s", flags.WC.Path))
+	}
+	if _, err := os.Stat(filepath.Join(flags.WC.Path, flags.Dockerfile)); err != nil {
+		common.BasicLogError(fmt.Sprintf("could not find %s", filepath.Join(flags.WC.Path, flags.Dockerfile)))
+	}
+
+	if flags.Engine == "" {
+		engine, err := common.DetectContainerEngine()
+		if err != nil {
+			common.BasicLogError(err.Error())
+		}
+		flags.Engine = engine
+	} else if !common.IsContainerEngine(flags.Engine) {
+		common.BasicLogError(fmt.Sprintf(
+			"unknown container engine %s (expected one of %s)",
+			flags.Engine,
+			strings.Join(common.ContainerEngines, ", "),
+		))
+	}
+	log.Printf("🐳 using %s", common.HiCyan(flags.Engine))
+
+	// image & container names are derived from the workflow (or directory) name
+	name := dockerName(flags.Name)
+	if flags.Container == "" {
+		flags.Container = "reload-" + name
+	}
+	if flags.Image == "" {
+		flags.Image = fmt.Sprintf("reload-%s:dev", name)
+	}
+
+	// create a new watcher
+	var w *fsnotify.Watcher
+	w, _ = fsnotify.NewWatcher()
+	defer w.Close()
+
+	// add hidden files
+	flags.WC.Ignore = append(flags.WC.Ignore, ".git")
+	flags.WC.Ignore = append(flags.WC.Ignore, "reload.toml")
+
+	err := common.AddToFileWatcher(w, &flags.WC)
+	if err != nil {
+		common.BasicLogError("failed to add watchlist to file watcher")
+	}
+	common.WatchEnvFiles(w, &flags.WC, flags.EnvFiles)
+
+	return runDockerReload(w, flags, stop)
+}
+
+func runDockerReload(watcher *fsnotify.Watcher, flags common.DockerFlags, stop <-chan struct{}) error {
+	// the container is removed on Ctrl-C and fatal errors (a failed build
+	// included), the logs process changes with every reload
+	var mu sync.Mutex
+	var proc *exec.Cmd
+	removeHook := common.OnExit(func() {
+		mu.Lock()
+		defer mu.Unlock()
+		log.Printf("🧹 %s", common.HiYlw(fmt.Sprintf("removing %s...", flags.Container)))
+		stopDockerContainer(flags, proc)
+	})
+	defer removeHook()
+	rerun := func() {
+		mu.Lock()
+		old := proc
+		mu.Unlock()
+		logs := runDockerCommands(flags, old)
+		mu.Lock()
+		proc = logs
+		mu.Unlock()
+	}
+
+	// run initial build
+	rerun()
+
+	loop := &common.Loop{
+		Watcher:  watcher,
+		WC:       &flags.WC,
+		EnvFiles: flags.EnvFiles,
+		// rebuild & replace the container after changes
+		Reload: rerun,
+		Stop: func() {
+			mu.Lock()
+			defer mu.Unlock()
+			stopDockerContainer(flags, proc)
+		},
+	}
+	loop.Run(stop)
+	return nil
+}
+
+// runDockerCommands builds the image, replaces the running container and
+// follows its logs (the returned process)
+func runDockerCommands(flags common.DockerFlags, oldLogs *exec.Cmd) *exec.Cmd {
+	// env files are read again on every run to pick up changes
+	env := common.Environ(flags.WC.Path, flags.Env, flags.EnvFiles)
+
+	// a failed build stops reload, just like a failed build step of a basic workflow
+	log.Printf("🏗️  %s", common.HiYlw("building..."))
+	if _, err := common.StartCommand(dockerBuildCommand(flags), flags.WC.Path, nil, true, flags.Verbose); err != nil {
+		common.BasicLogError("failed to execute build process")
+	}
+
+	if oldLogs != nil {
+		oldLogs.Process.Kill()
+		oldLogs.Wait()
+	}
+	removeDockerContainers(flags)
+
+	log.Printf("🏃 %s", common.HiGreen("running..."))
+	if _, err := common.StartCommand(dockerRunCommand(flags, env), flags.WC.Path, nil, true, false); err != nil {
+		common.BasicLogError("failed to execute run process")
+	}
+	if !flags.Verbose {
+		return nil
+	}
+
+	logs, err := common.StartCommand([]string{flags.Engine, "logs", "-f", flags.Container}, flags.WC.Path, nil, false, true)
+	if err != nil {
+		common.LogError(fmt.Sprintf("failed to stream the logs of %s", flags.Container))
+	}
+	return logs
+}
+
+func dockerBuildCommand(flags common.DockerFlags) []string {
+	args := []string{flags.Engine, "build", "-t", flags.Image, "-f", flags.Dockerfile}
+	if flags.NoCache {
+		args = append(args, "--no-cache")
+	}
+	keys := make([]string, 0, len(flags.BuildArgs))
+	for key := range flags.BuildArgs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		args = append(args, "--build-arg", fmt.Sprintf("%s=%s", key, flags.BuildArgs[key]))
+	}
+	return append(args, ".")
+}
+
+// dockerRunCommand starts the container in the background, env is the
+// workflow's env (and env files) passed to the container
+func dockerRunCommand(flags common.DockerFlags, env []string) []string {
+	args := []string{
+		flags.Engine, "run", "-d",
+		"--name", flags.Container,
+		"--label", fmt.Sprintf("%s=%s", containerLabel, flags.Name),
+		"--label", fmt.Sprintf("%s=%s", pathLabel, flags.WC.Path),
+	}
+	for _, port := range flags.Ports {
+		args = append(args, "-p", port)
+	}
+	for _, volume := range flags.Volumes {
+		args = append(args, "-v", dockerVolume(flags.WC.Path, volume))
+	}
+	for _, pair := range env {
+		args = append(args, "-e", pair)
+	}
+	return append(args, flags.Image)
+}
+
+// dockerVolume makes relative bind mount sources (./data:/data) absolute,
+// named volumes are left alone
+func dockerVolume(root, volume string) string {
+	parts := strings.SplitN(volume, ":", 2)
+	if len(parts) == 2 && (strings.HasPrefix(parts[0], ".") || strings.Contains(parts[0], string(filepath.Separator))) {
+		if !filepath.IsAbs(parts[0]) {
+			parts[0] = filepath.Join(root, parts[0])
+		}
+		return parts[0] + ":" + parts[1]
+	}
+	return volume
+}
+
+// removeDockerContainers removes the workflow's container by name, and any
+// container of the same project left behind under another name by its labels
+func removeDockerContainers(flags common.DockerFlags) {
+	containers := []string{flags.Container}
+	ps := []string{
+		flags.Engine, "ps", "-aq",
+		"--filter", fmt.Sprintf("label=%s=%s", containerLabel, flags.Name),
+		"--filter", fmt.Sprintf("label=%s=%s", pathLabel, flags.WC.Path),
+	}
+	if out, err := common.CommandOutput(ps, flags.WC.Path, nil); err == nil {
+		containers = append(containers, strings.Fields(string(out))...)
+	}
+
+	// rm fails when the container doesn't exist, which is fine
+	common.CommandOutput(append([]string{flags.Engine, "rm", "-f"}, containers...), flags.WC.Path, nil)
+}
+
+// stopDockerContainer stops following the logs and removes the container
+func stopDockerContainer(flags common.DockerFlags, logs *exec.Cmd) {
+	common.StopProcess(logs, stopGracePeriod)
+	removeDockerContainers(flags)
+}
+
+// dockerName turns a workflow name (like billing/api) into a valid image &
+// container name
+func dockerName(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.' {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune('-')
+		}
+	}
+	if b.Len() == 0 {
+		return "app"
+	}
+	return b.String()
+}
+
+// dockerFlagsTemplate converts the docker flags into a reload.toml workflow
+func dockerFlagsTemplate(name string, flags common.DockerFlags) initTemplate {
+	return initTemplate{
+		name:          name,
+		containerized: true,
+		verbose:       flags.Verbose,
+		path:          flags.WC.Path,
+		watch:         flags.WC.Watch,
+		ignore:        flags.WC.Ignore,
+		env:           flags.Env,
+		envFile:       flags.EnvFiles,
+		engine:        flags.Engine,
+		dockerfile:    flags.Dockerfile,
+		image:         flags.Image,
+		container:     flags.Container,
+		ports:         flags.Ports,
+		volumes:       flags.Volumes,
+		buildArgs:     flags.BuildArgs,
+		noCache:       flags.NoCache,
+	}
+}
@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"reload/common"
+	"strings"
+	"time"
+)
+
+const (
+	// how often `docker compose ps` is polled while waiting for services
+	healthPollInterval = 500 * time.Millisecond
+)
+
+// waitForServices polls the containers of some services (nil for all of them)
+// until they're running & healthy, one of them fails or the wait timeout runs
+// out. When the (re)start failed, it returns the services that aren't ready.
+func waitForServices(flags common.ComposeFlags, env []string, services []string) ([]string, error) {
+	if flags.WaitTimeout <= 0 {
+		return nil, nil
+	}
+
+	args := append([]string{"ps", "-a", "--format", "json"}, services...)
+	deadline := time.Now().Add(flags.WaitTimeout)
+	log.Printf("⏳ %s", common.HiYlw("waiting for the services to be healthy..."))
+	for {
+		out, err := runner.Output(composeCommand(flags, args...), flags.WC.Path, env)
+		var statuses []common.ServiceStatus
+		if err == nil {
+			statuses, err = common.ParseServiceStatus(out)
+		}
+		if err != nil {
+			// older engines don't support --format json
+			common.LogWarning(fmt.Sprintf("could not check the health of the services, not waiting (%s)", err))
+			return nil, nil
+		}
+
+		ready, failed := len(statuses) > 0, false
+		for _, status := range statuses {
+			ready = ready && status.Ready()
+			failed = failed || status.Failed()
+		}
+		// services without a container yet (engines that don't report the
+		// service can't tell)
+		missing := []string{}
+		for _, name := range services {
+			if reportsServices(statuses) && !hasContainer(statuses, name) {
+				missing = append(missing, name)
+				ready = false
+			}
+		}
+
+		timedOut := time.Now().After(deadline)
+		if !ready && !failed && !timedOut {
+			time.Sleep(healthPollInterval)
+			continue
+		}
+
+		logServiceStatus(statuses, missing)
+		if ready && !failed {
+			log.Printf("✅ %s", common.HiGreen("services are ready"))
+			return nil, nil
+		}
+
+		notReady := append([]string{}, missing...)
+		for _, status := range statuses {
+			if name := statusName(status); !status.Ready() && !contains(notReady, name) {
+				notReady = append(notReady, name)
+			}
+		}
+		if failed {
+			return notReady, fmt.Errorf("%s unhealthy or exited", strings.Join(notReady, ", "))
+		}
+		return notReady, fmt.Errorf("%s not healthy after %s", strings.Join(notReady, ", "), flags.WaitTimeout)
+	}
+}
+
+// statusName is the container's service, or its name for engines that don't
+// report the service
+func statusName(status common.ServiceStatus) string {
+	if status.Service == "" {
+		return status.Name
+	}
+	return status.Service
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func hasContainer(statuses []common.ServiceStatus, service string) bool {
+	for _, status := range statuses {
+		if statusName(status) == service {
+			return true
+		}
+	}
+	return false
+}
+
+func reportsServices(statuses []common.ServiceStatus) bool {
+	for _, status := range statuses {
+		if status.Service != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// logServiceStatus prints a line per container (and per missing service)
+func logServiceStatus(statuses []common.ServiceStatus, missing []string) {
+	for _, status := range statuses {
+		icon := "⏳"
+		if status.Failed() {
+			icon = "❌"
+		} else if status.Ready() {
+			icon = "💚"
+		}
+		log.Printf("%s %s %s", icon, common.HiCyan(statusName(status)), status.Status())
+	}
+	if len(missing) > 0 {
+		log.Printf("⏳ %s %s", common.HiCyan(strings.Join(missing, ", ")), "not created")
+	}
+}
@@ -1,11 +1,16 @@
 package cmd
 
 import (
+	"bufio"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
 	"reload/common"
+	"strings"
 
 	"github.com/spf13/cobra"
 )
@@ -14,6 +19,7 @@ var defaultTomlFile string = `# reload.toml config file
 
 # basic workflow (no subcommands)
 [basic]
+description = "build & run the project"
 containerized = false
 verbose = true
 path = "." # path to the project directory
@@ -21,61 +27,189 @@ watch = [ ] # files to watch
 ignore = [ ] # files to ignore
 build = [ ] # build shell commands
 run = ""
+env = { } # extra environment variables for build & run
+env_file = [ ] # .env files to load (changes restart the workflow)
 
 # make use of the 'docker compose' functionality
 [compose]
+description = "live reload docker compose services"
 containerized = true
 verbose = true
 path = "." # path to the project directory
 watch = [ ] # files to watch
 ignore = [ ] # files to ignore
 service = ""
+rebuild = false # docker compose build before (re)starting services
+on_exit = "stop" # stop, down, down-volumes or none
 
 # add as many as you like...
 `
 
+const (
+	initHeader = "# reload.toml config file\n"
+)
+
 var initCmd = &cobra.Command{
 	Use:   "init",
 	Short: "Create a reload.toml file to configure workflows for live reload functionality",
-	Run:   initRun,
+	Long: fmt.Sprintf(
+		"Create a reload.toml file with workflows for the stacks found in --path (%s)",
+		strings.Join(templateNames(), ", "),
+	),
+	Run: initRun,
 }
 
 func init() {
+	initCmd.Flags().StringSliceP(
+		"template",
+		"t",
+		[]string{},
+		fmt.Sprintf("Generate workflows for these stacks instead of detecting them (%s)", strings.Join(templateNames(), "|")),
+	)
+	initCmd.Flags().BoolP("force", "f", false, "Overwrite an existing reload.toml file")
+	initCmd.Flags().BoolP("interactive", "i", false, "Ask for the build & run commands of every workflow")
 	rootCmd.AddCommand(initCmd)
 }
 
 func initRun(cmd *cobra.Command, _ []string) {
 	path, _ := cmd.Flags().GetString("path")
+	names, _ := cmd.Flags().GetStringSlice("template")
+	force, _ := cmd.Flags().GetBool("force")
+	interactive, _ := cmd.Flags().GetBool("interactive")
 
-	// change directory
-	if err := os.Chdir(path); err != nil {
+	if info, err := os.Stat(path); err != nil || !info.IsDir() {
 		common.BasicLogError(fmt.Sprintf("unrecognized path %s", path))
-		os.Exit(1)
 	}
+	file := filepath.Join(path, common.ConfigFile)
 
 	// check if it iexists
-	if _, err := os.Stat("reload.toml"); err == nil {
+	if _, err := os.Stat(file); err == nil && !force {
 		log.Printf(
-			"%s\treload.toml file already exists",
+			"%s\treload.toml file already exists (use %s to overwrite it)",
 			common.ErrorRed("error"),
+			common.ExtraHiGreen("--force"),
 		)
 		log.Fatalf(
 			"%s\trun %s to get started",
 			common.ExtraHiYlw("tip"),
 			common.ExtraHiGreen("reload start"),
 		)
-	} else if errors.Is(err, os.ErrNotExist) {
-		f, err := os.Create("reload.toml")
-		if err != nil {
-			common.BasicLogError("failed to create reload.toml")
+	} else if err != nil && !errors.Is(err, os.ErrNotExist) {
+		common.BasicLogError(fmt.Sprintf("failed to read %s", file))
+	}
+
+	templates := initTemplates(path, names)
+	if interactive {
+		if len(templates) == 0 {
+			templates = []initTemplate{{name: "basic", description: "build & run the project", verbose: true, path: "."}}
 		}
-		f.WriteString(defaultTomlFile)
-		defer f.Close()
-		log.Printf("%s created", common.HiCyan("reload.toml"))
-		log.Printf(
-			"%s\trun %s to get started",
-			common.ExtraHiYlw("tip"),
-			common.ExtraHiGreen("reload start"),
-		)
+		askCommands(templates, os.Stdin)
+	}
+
+	content := defaultTomlFile
+	if len(templates) > 0 {
+		tables := make([]string, len(templates))
+		for i, t := range templates {
+			tables[i] = t.render()
+		}
+		content = initHeader + "\n" + strings.Join(tables, "\n") + "\n# add as many as you like...\n"
+	}
+
+	if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+		common.BasicLogError(fmt.Sprintf("failed to create %s", file))
+	}
+	log.Printf("%s created", common.HiCyan(file))
+	log.Printf(
+		"%s\trun %s to get started",
+		common.ExtraHiYlw("tip"),
+		common.ExtraHiGreen("reload start"),
+	)
+}
+
+// initTemplates returns the workflows for the --template stacks, or for the
+// stacks detected in dir
+func initTemplates(dir string, names []string) []initTemplate {
+	templates := []initTemplate{}
+	if len(names) > 0 {
+		for _, name := range names {
+			st, ok := findTemplate(name)
+			if !ok {
+				common.BasicLogError(fmt.Sprintf(
+					"unknown template %s (expected one of %s)",
+					name,
+					strings.Join(templateNames(), ", "),
+				))
+			}
+			templates = append(templates, st.build(dir))
+		}
+		return templates
+	}
+
+	for _, st := range stackTemplates {
+		marker, ok := st.detect(dir)
+		if !ok {
+			continue
+		}
+		// a Makefile is only worth a workflow when nothing else was found
+		if st.name == "make" && len(templates) > 0 {
+			continue
+		}
+		// compose already runs the Dockerfile
+		if st.name == "docker" && hasTemplate(templates, "compose") {
+			continue
+		}
+		log.Printf("🔍 found %s, adding a %s workflow", common.HiCyan(marker), common.HiYlw(st.name))
+		templates = append(templates, st.build(dir))
+	}
+	return templates
+}
+
+func hasTemplate(templates []initTemplate, name string) bool {
+	for _, t := range templates {
+		if t.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// askCommands prompts for the build & run commands of every workflow,
+// an empty answer keeps the suggested commands
+func askCommands(templates []initTemplate, in io.Reader) {
+	reader := bufio.NewReader(in)
+	ask := func(question, suggestion string) string {
+		fmt.Printf("%s [%s]: ", question, suggestion)
+		answer, _ := reader.ReadString('\n')
+		if answer = strings.TrimSpace(answer); answer != "" {
+			return answer
+		}
+		return suggestion
+	}
+
+	for i := range templates {
+		t := &templates[i]
+		if t.containerized && t.dockerfile != "" {
+			ports := ask(fmt.Sprintf("%s: ports to publish (HOST:CONTAINER, separated by ,)", t.name), strings.Join(t.ports, ","))
+			t.ports = []string{}
+			for _, p := range strings.Split(ports, ",") {
+				if p = strings.TrimSpace(p); p != "" {
+					t.ports = append(t.ports, p)
+				}
+			}
+			continue
+		}
+		if t.containerized {
+			t.service = ask(fmt.Sprintf("%s: docker compose service (empty for all)", t.name), t.service)
+			continue
+		}
+
+		build := ask(fmt.Sprintf("%s: build commands (separated by ;)", t.name), strings.Join(t.build, "; "))
+		t.build = []string{}
+		for _, b := range strings.Split(build, ";") {
+			if b = strings.TrimSpace(b); b != "" {
+				t.build = append(t.build, b)
+			}
+		}
+		t.run = ask(fmt.Sprintf("%s: run command", t.name), t.run)
 	}
 }
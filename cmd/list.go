@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"reload/common"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the workflows defined in the reload.toml file",
+	Args:  cobra.NoArgs,
+	Run:   listRun,
+}
+
+func init() {
+	rootCmd.AddCommand(listCmd)
+}
+
+func listRun(cmd *cobra.Command, _ []string) {
+	printWorkflows(readConfig(cmd))
+}
+
+func printWorkflows(conf *common.Config) {
+	if len(conf.Workflows) == 0 {
+		log.Printf("%s\tno workflows defined in reload.toml", common.Ylw("warning"))
+		return
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "WORKFLOW\tTYPE\tPATH\tRUN\tPROFILES\tDESCRIPTION")
+	for _, name := range conf.Names() {
+		wf := conf.Workflows[name]
+		profiles := strings.Join(wf.ProfileNames(), ",")
+		if profiles == "" {
+			profiles = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", name, wf.Type(), wf.Path, workflowRunCommand(wf), profiles, wf.Description)
+	}
+	tw.Flush()
+}
+
+// workflowRunCommand is the long running command a workflow starts
+func workflowRunCommand(wf *common.Workflow) string {
+	if wf.Type() == "docker" {
+		image := wf.Image
+		if image == "" {
+			image = fmt.Sprintf("reload-%s:dev", dockerName(wf.Name))
+		}
+		return fmt.Sprintf("docker run %s", image)
+	}
+	if wf.Containerized {
+		run, _ := composeCommands(wf.ComposeFlags())
+		return strings.Join(run, " ")
+	}
+	return wf.Run
+}
+
+// completeWorkflows suggests workflow names from reload.toml for shell completion
+func completeWorkflows(cmd *cobra.Command, args []string, _ string) ([]string, cobra.ShellCompDirective) {
+	file, err := configFile(cmd)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	conf, err := common.LoadConfig(file)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	picked := map[string]bool{}
+	for _, arg := range args {
+		picked[arg] = true
+	}
+
+	names := []string{}
+	for _, name := range conf.Names() {
+		if picked[name] {
+			continue
+		}
+		// zsh & fish display the text after the tab as a description
+		if desc := conf.Workflows[name].Description; desc != "" {
+			name = fmt.Sprintf("%s\t%s", name, desc)
+		}
+		names = append(names, name)
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeProfiles suggests the profiles of the workflows picked so far
+func completeProfiles(cmd *cobra.Command, args []string, _ string) ([]string, cobra.ShellCompDirective) {
+	file, err := configFile(cmd)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	conf, err := common.LoadConfig(file)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	seen := map[string]bool{}
+	profiles := []string{}
+	for _, arg := range args {
+		wf, ok := conf.Workflows[arg]
+		if !ok {
+			continue
+		}
+		for _, profile := range wf.ProfileNames() {
+			if !seen[profile] {
+				seen[profile] = true
+				profiles = append(profiles, profile)
+			}
+		}
+	}
+	return profiles, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeWorkflow only completes the first argument
+func completeWorkflow(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return completeWorkflows(cmd, args, toComplete)
+}
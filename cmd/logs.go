@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"fmt"
+	"reload/common"
+	"strings"
+	"sync"
+	"time"
+)
+
+// composeLogs streams `docker compose logs -f` with a process per service, so
+// restarting one service doesn't interrupt (or repeat) the logs of the others
+type composeLogs struct {
+	flags common.ComposeFlags
+	// followed services, "" follows the whole project in one process
+	services []string
+	procs    map[string]composeProcess
+	// the exit action stops the logs from another goroutine
+	mu      sync.Mutex
+	stopped bool
+}
+
+func newComposeLogs(flags common.ComposeFlags) *composeLogs {
+	l := &composeLogs{
+		flags:    flags,
+		services: composeServices(flags),
+		procs:    map[string]composeProcess{},
+	}
+	if len(l.services) > 0 || !flags.Verbose {
+		return l
+	}
+
+	// every service in the project (with the enabled profiles)
+	env := common.Environ(flags.WC.Path, flags.Env, flags.EnvFiles)
+	if out, err := runner.Output(composeCommand(flags, "config", "--services"), flags.WC.Path, env); err == nil {
+		l.services = strings.Fields(string(out))
+	}
+	if len(l.services) == 0 {
+		l.services = []string{""}
+	}
+	return l
+}
+
+// follow (re)starts streaming the logs of some services (nil for all of
+// them) from since, which is right before their containers were started
+func (l *composeLogs) follow(services []string, since time.Time) {
+	if !l.flags.Verbose {
+		return
+	}
+	if len(services) == 0 {
+		services = l.services
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.stopped {
+		return
+	}
+
+	env := common.Environ(l.flags.WC.Path, l.flags.Env, l.flags.EnvFiles)
+	for _, service := range services {
+		if proc, ok := l.procs[service]; ok {
+			proc.Kill()
+		}
+
+		// compose prefixes every line with the container's name
+		args := []string{"logs", "-f", "--since", since.UTC().Format(time.RFC3339Nano)}
+		if service != "" {
+			args = append(args, service)
+		}
+		proc, err := runner.Start(composeCommand(l.flags, args...), l.flags.WC.Path, env)
+		if err != nil {
+			common.LogError(fmt.Sprintf("failed to stream the logs of %s", service))
+			continue
+		}
+		l.procs[service] = proc
+	}
+}
+
+// stop stops streaming logs
+func (l *composeLogs) stop() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.stopped = true
+	for service, proc := range l.procs {
+		proc.Stop()
+		delete(l.procs, service)
+	}
+}
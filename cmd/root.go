@@ -6,12 +6,18 @@ import (
 	"os"
 	"os/exec"
 	"reload/common"
+	"time"
 
 	"github.com/fsnotify/fsnotify"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 )
 
+const (
+	// how long run processes get to exit before they're killed
+	stopGracePeriod = 5 * time.Second
+)
+
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "reload",
@@ -31,6 +37,12 @@ func Execute() {
 func init() {
 	// basic app support (run custom build and run commands)
 	rootCmd.PersistentFlags().StringP("path", "p", ".", "Path to watch files from")
+	rootCmd.PersistentFlags().StringP(
+		"config",
+		"c",
+		"",
+		"Path to the reload.toml file (default: the nearest reload.toml in --path or its parents)",
+	)
 	rootCmd.Flags().StringSliceP(
 		"watch",
 		"w",
@@ -45,11 +57,16 @@ func init() {
 	rootCmd.Flags().StringSliceP("build", "b", []string{}, "Shell command to build your project")
 	rootCmd.Flags().StringP("run", "r", "", "Shell command to run your project")
 	rootCmd.Flags().BoolP("verbose", "v", true, "Displays build and run output to the console")
+	rootCmd.Flags().String("save-as", "", "Save the flags as a workflow in reload.toml before starting")
 }
 
 func rootRun(cmd *cobra.Command, _ []string) {
 	flags := constructRootFlags(cmd.Flags())
-	err := startRootReload(flags)
+	if name, _ := cmd.Flags().GetString("save-as"); name != "" {
+		saveWorkflow(cmd, rootFlagsTemplate(name, flags))
+	}
+
+	err := startRootReload(flags, nil)
 	if err != nil {
 		common.BasicLogError("failed to start live reload")
 		os.Exit(1)
@@ -68,13 +85,33 @@ func constructRootFlags(flags *pflag.FlagSet) common.RootFlags {
 	return rf
 }
 
-func startRootReload(flags common.RootFlags) error {
+// rootFlagsTemplate converts the root flags into a reload.toml workflow
+func rootFlagsTemplate(name string, flags common.RootFlags) initTemplate {
+	return initTemplate{
+		name:    name,
+		verbose: flags.Verbose,
+		path:    flags.WC.Path,
+		watch:   flags.WC.Watch,
+		ignore:  flags.WC.Ignore,
+		build:   flags.Build,
+		run:     flags.Run,
+	}
+}
+
+// startRootReload watches files and reruns commands until stop is closed (a nil
+// stop channel runs forever)
+func startRootReload(flags common.RootFlags, stop <-chan struct{}) error {
 	// both build and run can't be empty (at the same time)
 	if len(flags.Build) <= 0 && flags.Run == "" {
 		common.BasicLogError("--build or --run flags must be set")
 		os.Exit(1)
 	}
 
+	// every command runs in (and every path is relative to) the absolute --path
+	if err := flags.WC.Resolve(); err != nil {
+		common.BasicLogError(fmt.Sprintf("unrecognized path %s", flags.WC.Path))
+	}
+
 	// add hidden files like .git
 	flags.WC.Ignore = append(flags.WC.Ignore, ".git")
 	flags.WC.Ignore = append(flags.WC.Ignore, "reload.toml")
@@ -89,60 +126,28 @@ func startRootReload(flags common.RootFlags) error {
 	if err != nil {
 		common.BasicLogError("failed to add watchlist to file watcher")
 	}
+	common.WatchEnvFiles(w, &flags.WC, flags.EnvFiles)
 
-	done := make(chan bool)
-	go runRootReload(w, flags)
-
-	// used to synchronize between the goroutine thread and the main thread
-	// we're forcing the main thread to wait until there is some data to be consumed in the done channel
-	<-done
+	runRootReload(w, flags, stop)
 	return nil
 }
 
-func runRootReload(watcher *fsnotify.Watcher, flags common.RootFlags) {
+func runRootReload(watcher *fsnotify.Watcher, flags common.RootFlags, stop <-chan struct{}) {
 	// run initial build
 	proc := runRootCommands(flags, nil)
-	for {
-		select {
-		case event, ok := <-watcher.Events:
-			if !ok {
-				common.BasicLogError("failed to read from watcher.Events channel")
-			}
-			if !common.IsExcluded(event.Name, flags.WC.Ignore) {
-				// listen for file changes
-				if event.Op&fsnotify.Create == fsnotify.Create {
-					common.LogEvent("a wild %s has appeared", event.Name)
-					flags.WC.Watch = append(flags.WC.Watch, event.Name)
-					common.AddToFileWatcher(watcher, &flags.WC)
-				} else if event.Op&fsnotify.Remove == fsnotify.Remove {
-					common.LogEvent("%s has been removed", event.Name)
-					// todo: handle removing from file watcher
-				} else if event.Op&fsnotify.Rename == fsnotify.Rename {
-					common.LogEvent("%s has a new name", event.Name)
-				} else if event.Op&fsnotify.Write == fsnotify.Write {
-					common.LogEvent("%s has changed", event.Name)
-				}
-				// rerun commands after changes
-				if event.Op&fsnotify.Chmod != fsnotify.Chmod {
-					proc = runRootCommands(flags, proc)
-				}
-			}
-		case err, ok := <-watcher.Errors:
-			if !ok {
-				common.BasicLogError("failed to read from watcher.Errors channel")
-			}
-			common.BasicLogError(fmt.Sprintf("%+v", err))
-		}
+
+	loop := &common.Loop{
+		Watcher:  watcher,
+		WC:       &flags.WC,
+		EnvFiles: flags.EnvFiles,
+		// rerun commands after changes
+		Reload: func() { proc = runRootCommands(flags, proc) },
+		Stop:   func() { common.StopProcess(proc, stopGracePeriod) },
 	}
+	loop.Run(stop)
 }
 
 func runRootCommands(flags common.RootFlags, oldRunProc *exec.Cmd) *exec.Cmd {
-	// setup (change dir)
-	// change current working directory
-	if err := os.Chdir(flags.WC.Path); err != nil {
-		common.BasicLogError(fmt.Sprintf("unrecognized path %s", flags.WC.Path))
-	}
-
 	// cleanup
 	if oldRunProc != nil {
 		oldRunProc.Process.Kill()
@@ -154,11 +159,14 @@ func runRootCommands(flags common.RootFlags, oldRunProc *exec.Cmd) *exec.Cmd {
 		}
 	}
 
+	// env files are read again on every run to pick up changes
+	env := common.Environ(flags.WC.Path, flags.Env, flags.EnvFiles)
+
 	// run build
 	if len(flags.Build) > 0 {
 		log.Printf("🏗️  %s", common.HiYlw("building..."))
 		for _, cmd := range flags.Build {
-			if _, err := common.StartProcess(cmd, flags.WC.Path, true, flags.Verbose); err != nil {
+			if _, err := common.StartProcess(cmd, flags.WC.Path, env, true, flags.Verbose); err != nil {
 				common.BasicLogError("failed to execute build process")
 			}
 		}
@@ -170,7 +178,7 @@ func runRootCommands(flags common.RootFlags, oldRunProc *exec.Cmd) *exec.Cmd {
 	if flags.Run != "" {
 		// execute run cmd
 		log.Printf("🏃 %s", common.HiGreen("running..."))
-		runProc, err = common.StartProcess(flags.Run, flags.WC.Path, false, flags.Verbose)
+		runProc, err = common.StartProcess(flags.Run, flags.WC.Path, env, false, flags.Verbose)
 		if err != nil {
 			common.BasicLogError("failed to execute run process")
 		}
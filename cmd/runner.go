@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"os/exec"
+	"reload/common"
+)
+
+// composeRunner runs compose commands. Every compose invocation goes through
+// it, so tests can swap the engine for a fake one.
+type composeRunner interface {
+	// Run runs a command to completion, printing its output when verbose
+	Run(cmd []string, dir string, env []string, verbose bool) error
+	// Output runs a command to completion and returns what it printed to stdout
+	Output(cmd []string, dir string, env []string) ([]byte, error)
+	// Start starts a long-running command (like logs -f) that prints its output
+	Start(cmd []string, dir string, env []string) (composeProcess, error)
+}
+
+// composeProcess is a command started with composeRunner.Start
+type composeProcess interface {
+	// Kill kills the process and waits for it to exit
+	Kill()
+	// Stop asks the process to exit and kills it after the grace period
+	Stop()
+}
+
+// runner runs the compose commands of compose workflows
+var runner composeRunner = execRunner{}
+
+// execRunner runs compose commands as child processes
+type execRunner struct{}
+
+func (execRunner) Run(cmd []string, dir string, env []string, verbose bool) error {
+	_, err := common.StartCommand(cmd, dir, env, true, verbose)
+	return err
+}
+
+func (execRunner) Output(cmd []string, dir string, env []string) ([]byte, error) {
+	return common.CommandOutput(cmd, dir, env)
+}
+
+func (execRunner) Start(cmd []string, dir string, env []string) (composeProcess, error) {
+	c, err := common.StartCommand(cmd, dir, env, false, true)
+	if err != nil {
+		return nil, err
+	}
+	return execProcess{c}, nil
+}
+
+type execProcess struct {
+	cmd *exec.Cmd
+}
+
+func (p execProcess) Kill() {
+	p.cmd.Process.Kill()
+	p.cmd.Wait()
+}
+
+func (p execProcess) Stop() {
+	common.StopProcess(p.cmd, stopGracePeriod)
+}
@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"reload/common"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// saveWorkflow writes a workflow table to reload.toml, replacing a workflow
+// with the same name and leaving everything else (comments too) untouched.
+// reload.yaml and reload.json are decoded and written back in their format.
+func saveWorkflow(cmd *cobra.Command, t initTemplate) {
+	file, err := configFile(cmd)
+	if errors.Is(err, os.ErrNotExist) {
+		path, _ := cmd.Flags().GetString("path")
+		file = filepath.Join(path, common.ConfigFile)
+	} else if err != nil {
+		common.BasicLogError(fmt.Sprintf("failed to find reload.toml: %+v", err))
+	}
+
+	file, err = filepath.Abs(file)
+	if err != nil {
+		common.BasicLogError(fmt.Sprintf("unrecognized path %s", file))
+	}
+
+	// the workflow path is relative to reload.toml, the flag to the current directory
+	if path, err := filepath.Abs(t.path); err == nil {
+		if rel, err := filepath.Rel(filepath.Dir(file), path); err == nil {
+			t.path = rel
+		}
+	}
+
+	format, err := common.ConfigFormat(file)
+	if err != nil {
+		common.BasicLogError(err.Error())
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		common.BasicLogError(fmt.Sprintf("failed to read %s", file))
+	}
+
+	var content []byte
+	if format == common.FormatTOML {
+		if len(data) == 0 {
+			data = []byte(initHeader)
+		}
+		content = []byte(replaceTable(string(data), t.name, t.render()))
+	} else if content, err = replaceWorkflow(data, format, t); err != nil {
+		common.BasicLogError(fmt.Sprintf("failed to save workflow %s: %+v", t.name, err))
+	}
+
+	// never leave a broken config file behind
+	if _, err := common.ParseConfigFormat(content, format, filepath.Dir(file)); err != nil {
+		common.BasicLogError(fmt.Sprintf("failed to save workflow %s: %+v", t.name, err))
+	}
+	if err := ioutil.WriteFile(file, content, 0644); err != nil {
+		common.BasicLogError(fmt.Sprintf("failed to write %s", file))
+	}
+
+	log.Printf("💾 saved workflow %s to %s", common.HiYlw(t.name), common.HiCyan(file))
+	log.Printf(
+		"%s\trun %s next time",
+		common.ExtraHiYlw("tip"),
+		common.ExtraHiGreen(fmt.Sprintf("reload start %s", t.name)),
+	)
+}
+
+// replaceWorkflow sets the workflow in a yaml or json config file. The file is
+// decoded and encoded again, so its keys end up sorted (and comments are lost).
+func replaceWorkflow(data []byte, format string, t initTemplate) ([]byte, error) {
+	raw := map[string]interface{}{}
+	if len(bytes.TrimSpace(data)) > 0 {
+		var err error
+		if raw, err = common.DecodeRaw(data, format); err != nil {
+			return nil, err
+		}
+	}
+
+	// the workflow is rendered like it is for reload.toml, then converted
+	table, err := common.DecodeRaw([]byte(t.render()), common.FormatTOML)
+	if err != nil {
+		return nil, err
+	}
+	raw[t.name] = table[t.name]
+
+	var buf bytes.Buffer
+	if err := common.EncodeRaw(&buf, raw, format); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// replaceTable swaps the [name] table (and its [name.*] sub tables) for
+// table, or appends table when there's no workflow called name yet
+func replaceTable(content, name, table string) string {
+	lines := strings.Split(content, "\n")
+
+	start, end := -1, len(lines)
+	for i, line := range lines {
+		header := tableHeader(line)
+		if header == "" {
+			continue
+		}
+
+		ours := header == name || strings.HasPrefix(header, name+".")
+		if start < 0 && ours {
+			start = i
+		} else if start >= 0 && !ours {
+			end = i
+			break
+		}
+	}
+
+	if start < 0 {
+		return strings.TrimRight(content, "\n") + "\n\n" + table
+	}
+
+	// comments & blank lines right before the next table belong to it
+	for end > start+1 {
+		line := strings.TrimSpace(lines[end-1])
+		if line != "" && !strings.HasPrefix(line, "#") {
+			break
+		}
+		end--
+	}
+
+	// the rendered table already ends with a new line
+	rest := strings.Join(lines[end:], "\n")
+	before := strings.Join(lines[:start], "\n")
+	if start > 0 {
+		before += "\n"
+	}
+	return before + table + rest
+}
+
+// tableHeader returns the name of a [table] header line
+func tableHeader(line string) string {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "[") || strings.HasPrefix(line, "[[") {
+		return ""
+	}
+	return strings.Trim(strings.SplitN(line, "]", 2)[0], `[ "'`)
+}
@@ -1,157 +1,262 @@
 package cmd
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"log"
-	"os"
+	"path/filepath"
 	"reload/common"
+	"sort"
+	"time"
 
-	"github.com/BurntSushi/toml"
+	"github.com/fsnotify/fsnotify"
 	"github.com/spf13/cobra"
 )
 
-// constants
-var (
-	basicKeys = []string{
-		"containerized",
-		"verbose",
-		"path",
-		"watch",
-		"ignore",
-	}
-	composeKeys = []string{"service"}
-	rootKeys    = []string{
-		"build",
-		"run",
-	}
+const (
+	// editors write files in bursts, wait this long before reloading the config
+	configSettleTime = 300 * time.Millisecond
 )
 
 var startCmd = &cobra.Command{
-	Use:   "start workflow",
-	Short: "Run a custom workflow from the reload.toml file (no more nasty flags 🤮)",
-	Run:   startRun,
+	Use:               "start workflow...",
+	Short:             "Run custom workflows from the reload.toml file (no more nasty flags 🤮)",
+	ValidArgsFunction: completeWorkflows,
+	Run:               startRun,
 }
 
 func init() {
+	startCmd.Flags().String("profile", "", "Apply a profile from reload.toml to the workflows ([workflow.profiles.name])")
+	startCmd.RegisterFlagCompletionFunc("profile", completeProfiles)
 	rootCmd.AddCommand(startCmd)
 }
 
-func keysExist(title string, conf map[string]interface{}, keys []string) {
-	for _, key := range keys {
-		if _, ok := conf[key]; !ok {
-			msg := fmt.Sprintf(
-				"workflow %s expected key %s, but none was provided",
-				common.HiYlw(title),
-				common.Mgnta(key))
-			common.BasicLogError(msg)
-			os.Exit(1)
+func startRun(cmd *cobra.Command, args []string) {
+	conf := readConfig(cmd)
+	profile, _ := cmd.Flags().GetString("profile")
+
+	// show what's available when no workflow is picked
+	if len(args) == 0 {
+		printWorkflows(conf)
+		log.Printf(
+			"%s\trun %s to start one",
+			common.ExtraHiYlw("tip"),
+			common.ExtraHiGreen("reload start <workflow>"),
+		)
+		return
+	}
+
+	// look up every workflow before starting any of them
+	workflows := make([]*common.Workflow, len(args))
+	for i, arg := range args {
+		if _, ok := conf.Workflows[arg]; !ok {
+			common.BasicLogError(fmt.Sprintf(
+				"could not find workflow %s in reload.toml (run %s to see them all)",
+				arg,
+				common.ExtraHiGreen("reload list"),
+			))
+		}
+		workflow, err := conf.Workflow(arg, profile)
+		if err != nil {
+			common.BasicLogError(fmt.Sprintf("%s: %+v", conf.File, err))
 		}
+		workflows[i] = workflow
 	}
-}
 
-func getStringSlice(in []interface{}) []string {
-	s := make([]string, len(in))
-	for i, val := range in {
-		s[i] = val.(string)
+	// compose workflows clean up their containers on Ctrl-C
+	common.HandleSignals()
+
+	// workflows don't share any state (paths are absolute), so they can all
+	// run side by side in this process
+	running := map[string]*runningWorkflow{}
+	for _, workflow := range workflows {
+		if workflow.Profile != "" {
+			log.Printf("🎛️  starting %s with profile %s", common.HiYlw(workflow.Name), common.HiCyan(workflow.Profile))
+		}
+		running[workflow.Name] = runWorkflow(workflow, conf.Files())
 	}
 
-	return s
+	watchConfig(conf, running)
 }
 
-func startRun(cmd *cobra.Command, args []string) {
-	var arg string = ""
-	if len(args) > 0 {
-		arg = args[0]
-	} else {
-		common.BasicLogError("no workflow name provided")
+// runningWorkflow is a workflow started by `reload start`
+type runningWorkflow struct {
+	workflow *common.Workflow
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+func runWorkflow(workflow *common.Workflow, configFiles []string) *runningWorkflow {
+	rw := &runningWorkflow{
+		workflow: workflow,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
 	}
 
-	// read path
-	path, _ := cmd.Flags().GetString("path")
+	go func() {
+		defer close(rw.done)
+		startWorkflow(workflow, configFiles, rw.stop)
+	}()
+	return rw
+}
+
+// shutdown stops the workflow's watcher & processes and waits for them
+func (rw *runningWorkflow) shutdown() {
+	close(rw.stop)
+	<-rw.done
+}
 
-	// change directory
-	if err := os.Chdir(path); err != nil {
-		common.BasicLogError(fmt.Sprintf("unrecognized path %s", path))
-		os.Exit(1)
+func startWorkflow(workflow *common.Workflow, configFiles []string, stop <-chan struct{}) {
+	if workflow.Type() == "docker" {
+		// run the Dockerfile workflow
+		flags := workflow.DockerFlags()
+		flags.WC.Ignore = append(flags.WC.Ignore, configFiles...)
+		if err := startDockerReload(flags, stop); err != nil {
+			common.BasicLogError(fmt.Sprintf("failed to run workflow %s", workflow.Name))
+		}
+	} else if workflow.Containerized {
+		// run the docker compose workflow
+		flags := workflow.ComposeFlags()
+		flags.WC.Ignore = append(flags.WC.Ignore, configFiles...)
+		if err := startComposeReload(flags, stop); err != nil {
+			common.BasicLogError(fmt.Sprintf("failed to run workflow %s", workflow.Name))
+		}
+	} else {
+		// run the basic workflow
+		flags := workflow.RootFlags()
+		flags.WC.Ignore = append(flags.WC.Ignore, configFiles...)
+		if err := startRootReload(flags, stop); err != nil {
+			common.BasicLogError(fmt.Sprintf("failed to run workflow %s", workflow.Name))
+		}
 	}
+}
 
-	// read file
-	tomlData, err := ioutil.ReadFile("reload.toml")
+// watchConfig reloads the config file whenever it (or an included file)
+// changes and restarts the running workflows whose definition changed
+func watchConfig(conf *common.Config, running map[string]*runningWorkflow) {
+	file := conf.File
+	w, err := fsnotify.NewWatcher()
+	if err == nil {
+		defer w.Close()
+		err = watchConfigFiles(w, conf.Files())
+	}
 	if err != nil {
-		log.Printf(
-			"%s\tcould not find a reload.toml file",
-			common.ErrorRed("error"),
-		)
-		log.Fatalf(
-			"%s\trun %s to create one",
-			common.ExtraHiYlw("tip"),
-			common.ExtraHiGreen("reload init"),
-		)
-		os.Exit(1)
-	}
-
-	// Decode the config
-	var conf map[string]interface{}
-	if _, err := toml.Decode(string(tomlData), &conf); err != nil {
-		common.BasicLogError(fmt.Sprintf("reload.%+v", err))
-		os.Exit(1)
-	}
-
-	// check if specified workflow exists
-	workflow, ok := conf[arg]
-	if !ok {
-		common.BasicLogError(fmt.Sprintf("could not find workflow %s in reload.toml", arg))
-		os.Exit(1)
-	}
-
-	// type switching (fancy...)
-	switch workflowMap := workflow.(type) {
-	case map[string]interface{}:
-		keysExist(arg, workflowMap, basicKeys)
-
-		containerized := workflowMap["containerized"]
-		if containerized.(bool) {
-			// Check command level keys
-			keysExist(arg, workflowMap, composeKeys)
-
-			// Construct docker compose flags
-			cf := common.ComposeFlags{
-				WC: common.WatcherConfig{
-					Path:   workflowMap["path"].(string),
-					Watch:  getStringSlice(workflowMap["watch"].([]interface{})),
-					Ignore: getStringSlice(workflowMap["ignore"].([]interface{})),
-				},
-				Service: workflowMap["service"].(string),
-				Verbose: workflowMap["verbose"].(bool),
-			}
+		common.LogWarning(fmt.Sprintf("failed to watch %s, changes to it need a restart", file))
+		for _, rw := range running {
+			<-rw.done
+		}
+		return
+	}
 
-			// run the docker compose workflow
-			if err = startComposeReload(cf); err != nil {
-				common.BasicLogError(fmt.Sprintf("failed to run workflow %s", arg))
-				os.Exit(1)
+	files := configFileSet(conf.Files())
+
+	// editors write files in bursts, wait for them to settle
+	var settled <-chan time.Time
+	for {
+		select {
+		case event, ok := <-w.Events:
+			if !ok {
+				common.BasicLogError("failed to read from watcher.Events channel")
 			}
-		} else {
-			// Check command level keys
-			keysExist(arg, workflowMap, rootKeys)
-
-			// Construct docker compose flags
-			rf := common.RootFlags{
-				WC: common.WatcherConfig{
-					Path:   workflowMap["path"].(string),
-					Watch:  getStringSlice(workflowMap["watch"].([]interface{})),
-					Ignore: getStringSlice(workflowMap["ignore"].([]interface{})),
-				},
-				Build:   getStringSlice(workflowMap["build"].([]interface{})),
-				Run:     workflowMap["run"].(string),
-				Verbose: workflowMap["verbose"].(bool),
+			if files[filepath.Clean(event.Name)] && event.Op&fsnotify.Chmod != fsnotify.Chmod {
+				settled = time.After(configSettleTime)
 			}
-
-			// run the docker compose workflow
-			if err = startRootReload(rf); err != nil {
-				common.BasicLogError(fmt.Sprintf("failed to run workflow %s", arg))
-				os.Exit(1)
+		case <-settled:
+			settled = nil
+			// includes can change with the config, pick up new files
+			if conf := applyConfig(file, running); conf != nil {
+				watchConfigFiles(w, conf.Files())
+				files = configFileSet(conf.Files())
 			}
+		case err, ok := <-w.Errors:
+			if !ok {
+				common.BasicLogError("failed to read from watcher.Errors channel")
+			}
+			common.LogError(fmt.Sprintf("%+v", err))
 		}
 	}
 }
+
+// applyConfig restarts the workflows that changed and returns the new
+// config. The new config is only used if it parses and every running
+// workflow's variables can be expanded, otherwise nothing changes and nil is
+// returned.
+func applyConfig(file string, running map[string]*runningWorkflow) *common.Config {
+	conf, err := common.LoadConfig(file)
+	if err != nil {
+		common.LogError(fmt.Sprintf("%s: %+v", file, err))
+		common.LogWarning("keeping the previous configuration")
+		return nil
+	}
+	for _, warning := range conf.Warnings {
+		common.LogWarning(fmt.Sprintf("%s: %s", file, warning))
+	}
+
+	names := make([]string, 0, len(running))
+	for name := range running {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	changed := []*common.Workflow{}
+	for _, name := range names {
+		if _, ok := conf.Workflows[name]; !ok {
+			common.LogError(fmt.Sprintf("workflow %s was removed from %s", name, file))
+			common.LogWarning("keeping the previous configuration")
+			return nil
+		}
+		// the profile picked on the command line sticks across reloads
+		workflow, err := conf.Workflow(name, running[name].workflow.Profile)
+		if err != nil {
+			common.LogError(fmt.Sprintf("%s: %+v", file, err))
+			common.LogWarning("keeping the previous configuration")
+			return nil
+		}
+		if sameWorkflow(running[name].workflow, workflow) {
+			continue
+		}
+
+		// checks can depend on what the build creates, so they only warn
+		for _, err := range workflow.Check() {
+			common.LogWarning(fmt.Sprintf("%s: %s", file, err))
+		}
+		changed = append(changed, workflow)
+	}
+
+	if len(changed) == 0 {
+		log.Printf("%s\t%s changed, but none of the running workflows did", common.HiCyan("[INFO]"), file)
+		return conf
+	}
+	for _, workflow := range changed {
+		common.LogEvent("🔁 workflow %s changed, restarting it", workflow.Name)
+		running[workflow.Name].shutdown()
+		running[workflow.Name] = runWorkflow(workflow, conf.Files())
+	}
+	return conf
+}
+
+// watchConfigFiles watches the directories of the config files, editors often
+// replace files instead of writing to them
+func watchConfigFiles(w *fsnotify.Watcher, files []string) error {
+	for _, file := range files {
+		if err := w.Add(filepath.Dir(file)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func configFileSet(files []string) map[string]bool {
+	set := map[string]bool{}
+	for _, file := range files {
+		set[file] = true
+	}
+	return set
+}
+
+func sameWorkflow(a, b *common.Workflow) bool {
+	aJSON, _ := json.Marshal(a)
+	bJSON, _ := json.Marshal(b)
+	return bytes.Equal(aJSON, bJSON)
+}
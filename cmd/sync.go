@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"reload/common"
+	"strings"
+)
+
+// syncTarget is a changed file and where it lives in the container
+type syncTarget struct {
+	local     string
+	container string
+}
+
+// syncService copies changed files into a running service (and removes the
+// deleted ones) instead of restarting it. It returns false when the service
+// still has to be restarted: a file isn't synced or syncing failed.
+func syncService(flags common.ComposeFlags, env []string, service string, files []string) bool {
+	svc := flags.Services[service]
+	if len(svc.Sync) == 0 {
+		return false
+	}
+
+	targets := []syncTarget{}
+	seen := map[string]bool{}
+	for _, file := range files {
+		if seen[file] {
+			continue
+		}
+		seen[file] = true
+
+		target, ok := syncPath(flags.WC.Path, svc.Sync, file)
+		if !ok {
+			return false
+		}
+		targets = append(targets, syncTarget{local: file, container: target})
+	}
+
+	for _, t := range targets {
+		var args []string
+		if info, err := os.Stat(t.local); err != nil {
+			// deleted (or renamed) locally
+			args = composeCommand(flags, "exec", "-T", service, "rm", "-rf", t.container)
+		} else if info.IsDir() {
+			// the trailing /. copies the directory's content, not the directory into itself
+			args = composeCommand(flags, "cp", t.local+"/.", fmt.Sprintf("%s:%s", service, t.container))
+		} else {
+			args = composeCommand(flags, "cp", t.local, fmt.Sprintf("%s:%s", service, t.container))
+		}
+
+		if err := runner.Run(args, flags.WC.Path, env, flags.Verbose); err != nil {
+			common.LogError(fmt.Sprintf("failed to sync %s into %s, restarting it", t.local, service))
+			return false
+		}
+	}
+
+	if svc.SyncCommand != "" {
+		args := composeCommand(flags, "exec", "-T", service, "sh", "-c", svc.SyncCommand)
+		if err := runner.Run(args, flags.WC.Path, env, flags.Verbose); err != nil {
+			common.LogError(fmt.Sprintf("failed to run %q in %s, restarting it", svc.SyncCommand, service))
+			return false
+		}
+	}
+
+	log.Printf("🔄 %s %d file(s) into %s", common.HiGreen("synced"), len(targets), common.HiCyan(service))
+	return true
+}
+
+// syncPath maps a local file to its path in the container, ok is false when
+// the file isn't in any synced path
+func syncPath(root string, sync map[string]string, file string) (string, bool) {
+	rel, err := filepath.Rel(root, file)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return "", false
+	}
+
+	// the most specific synced path wins
+	best, target := "", ""
+	for local, container := range sync {
+		local = filepath.Clean(local)
+		inside, err := filepath.Rel(local, rel)
+		if err != nil || strings.HasPrefix(inside, "..") {
+			continue
+		}
+		if best == "" || len(local) > len(best) {
+			best, target = local, container
+		}
+	}
+	if best == "" {
+		return "", false
+	}
+
+	inside, _ := filepath.Rel(best, rel)
+	return path.Join(target, filepath.ToSlash(inside)), true
+}
@@ -0,0 +1,366 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reload/common"
+	"sort"
+	"strings"
+)
+
+// initTemplate is a workflow `reload init` (or --save-as) writes to reload.toml
+type initTemplate struct {
+	name          string
+	description   string
+	containerized bool
+	verbose       bool
+	path          string
+	watch         []string
+	ignore        []string
+	build         []string
+	run           string
+	service       string
+	// compose services with their own watch & ignore globs
+	services map[string]common.ComposeService
+	// compose image builds
+	rebuild   bool
+	buildArgs map[string]string
+	noCache   bool
+	// docker compose options
+	engine   string
+	files    []string
+	project  string
+	profiles []string
+	envFiles []string
+	onExit   string
+	// empty is the default timeout
+	waitTimeout string
+	// Dockerfile workflows
+	dockerfile string
+	image      string
+	container  string
+	ports      []string
+	volumes    []string
+	env        map[string]string
+	envFile    []string
+}
+
+// stackTemplate detects a stack from the files in a directory
+type stackTemplate struct {
+	name string
+	// any of these files means the stack is used
+	markers []string
+	// builds the workflow, it can look at the directory for details
+	build func(dir string) initTemplate
+}
+
+var stackTemplates = []stackTemplate{
+	{
+		name:    "go",
+		markers: []string{"go.mod"},
+		build:   goTemplate,
+	},
+	{
+		name:    "node",
+		markers: []string{"package.json"},
+		build:   nodeTemplate,
+	},
+	{
+		name:    "python",
+		markers: []string{"pyproject.toml", "requirements.txt", "setup.py", "manage.py"},
+		build:   pythonTemplate,
+	},
+	{
+		name:    "compose",
+		markers: []string{"compose.yaml", "compose.yml", "docker-compose.yaml", "docker-compose.yml"},
+		build:   composeTemplate,
+	},
+	{
+		name:    "docker",
+		markers: []string{"Dockerfile"},
+		build:   dockerTemplate,
+	},
+	{
+		name:    "make",
+		markers: []string{"Makefile"},
+		build:   makeTemplate,
+	},
+}
+
+// templateNames lists the stacks that can be passed to --template
+func templateNames() []string {
+	names := make([]string, len(stackTemplates))
+	for i, st := range stackTemplates {
+		names[i] = st.name
+	}
+	return names
+}
+
+func findTemplate(name string) (stackTemplate, bool) {
+	for _, st := range stackTemplates {
+		if st.name == name {
+			return st, true
+		}
+	}
+	return stackTemplate{}, false
+}
+
+// detect returns the first marker file found in dir
+func (st stackTemplate) detect(dir string) (string, bool) {
+	for _, marker := range st.markers {
+		if _, err := os.Stat(filepath.Join(dir, marker)); err == nil {
+			return marker, true
+		}
+	}
+	return "", false
+}
+
+func goTemplate(dir string) initTemplate {
+	return initTemplate{
+		name:        "go",
+		description: "build & run the go module",
+		verbose:     true,
+		path:        ".",
+		ignore:      []string{"tmp", "vendor"},
+		build:       []string{"go build -o tmp/app ."},
+		run:         "./tmp/app",
+	}
+}
+
+func nodeTemplate(dir string) initTemplate {
+	t := initTemplate{
+		name:        "node",
+		description: "run the node app",
+		verbose:     true,
+		path:        ".",
+		ignore:      []string{"node_modules", "dist", "build", "coverage"},
+	}
+
+	pm := "npm"
+	if exists(filepath.Join(dir, "yarn.lock")) {
+		pm = "yarn"
+	} else if exists(filepath.Join(dir, "pnpm-lock.yaml")) {
+		pm = "pnpm"
+	}
+
+	var pkg struct {
+		Scripts map[string]string `json:"scripts"`
+	}
+	if data, err := ioutil.ReadFile(filepath.Join(dir, "package.json")); err == nil {
+		json.Unmarshal(data, &pkg)
+	}
+
+	if _, ok := pkg.Scripts["build"]; ok {
+		t.build = []string{fmt.Sprintf("%s run build", pm)}
+	}
+	if _, ok := pkg.Scripts["start"]; ok {
+		t.run = fmt.Sprintf("%s start", pm)
+	} else {
+		t.run = "node index.js"
+	}
+	return t
+}
+
+func pythonTemplate(dir string) initTemplate {
+	t := initTemplate{
+		name:        "python",
+		description: "run the python app",
+		verbose:     true,
+		path:        ".",
+		ignore:      []string{".venv", "venv", "__pycache__", ".pytest_cache"},
+		run:         "python main.py",
+	}
+
+	switch {
+	case exists(filepath.Join(dir, "manage.py")):
+		t.run = "python manage.py runserver --noreload"
+	case exists(filepath.Join(dir, "app.py")):
+		t.run = "python app.py"
+	}
+	return t
+}
+
+func composeTemplate(dir string) initTemplate {
+	return initTemplate{
+		name:          "compose",
+		description:   "live reload docker compose services",
+		containerized: true,
+		verbose:       true,
+		path:          ".",
+	}
+}
+
+func dockerTemplate(dir string) initTemplate {
+	return initTemplate{
+		name:          "docker",
+		description:   "build & run the Dockerfile",
+		containerized: true,
+		verbose:       true,
+		path:          ".",
+		dockerfile:    "Dockerfile",
+	}
+}
+
+func makeTemplate(dir string) initTemplate {
+	return initTemplate{
+		name:        "make",
+		description: "run the default make target",
+		verbose:     true,
+		path:        ".",
+		build:       []string{"make"},
+	}
+}
+
+// render writes the template as a reload.toml workflow table
+func (t initTemplate) render() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[%s]\n", tomlKey(t.name))
+	if t.description != "" {
+		fmt.Fprintf(&b, "description = %s\n", tomlQuote(t.description))
+	}
+	fmt.Fprintf(&b, "containerized = %t\n", t.containerized)
+	fmt.Fprintf(&b, "verbose = %t\n", t.verbose)
+	fmt.Fprintf(&b, "path = %s # path to the project directory\n", tomlQuote(t.path))
+	fmt.Fprintf(&b, "watch = %s # files to watch\n", tomlList(t.watch))
+	fmt.Fprintf(&b, "ignore = %s # files to ignore\n", tomlList(t.ignore))
+	if t.containerized && t.dockerfile != "" {
+		t.renderDocker(&b)
+		return b.String()
+	}
+	if t.containerized {
+		service, services := t.service, []string{}
+		for name := range t.services {
+			services = append(services, name)
+		}
+		sort.Strings(services)
+		// a single service that reloads on any change fits in the service key
+		if service == "" && len(services) == 1 {
+			if svc := t.services[services[0]]; len(svc.Watch) == 0 && len(svc.Ignore) == 0 && len(svc.Sync) == 0 && svc.SyncCommand == "" {
+				service, services = services[0], nil
+			}
+		}
+
+		fmt.Fprintf(&b, "service = %s # empty for every service\n", tomlQuote(service))
+		fmt.Fprintf(&b, "rebuild = %t # docker compose build before (re)starting services\n", t.rebuild)
+		if t.noCache {
+			fmt.Fprintf(&b, "no_cache = true\n")
+		}
+		if len(t.buildArgs) > 0 {
+			fmt.Fprintf(&b, "build_args = %s\n", tomlTable(t.buildArgs))
+		}
+		if t.engine != "" {
+			fmt.Fprintf(&b, "engine = %s\n", tomlQuote(t.engine))
+		}
+		if len(t.files) > 0 {
+			fmt.Fprintf(&b, "compose_file = %s\n", tomlList(t.files))
+		}
+		if t.project != "" {
+			fmt.Fprintf(&b, "project = %s\n", tomlQuote(t.project))
+		}
+		if len(t.profiles) > 0 {
+			fmt.Fprintf(&b, "compose_profiles = %s\n", tomlList(t.profiles))
+		}
+		if len(t.envFiles) > 0 {
+			fmt.Fprintf(&b, "compose_env_file = %s\n", tomlList(t.envFiles))
+		}
+		if t.waitTimeout != "" {
+			fmt.Fprintf(&b, "wait_timeout = %s # \"0\" doesn't wait for healthy services\n", tomlQuote(t.waitTimeout))
+		}
+		if t.onExit != "" && t.onExit != common.ExitStop {
+			fmt.Fprintf(&b, "on_exit = %s # %s\n", tomlQuote(t.onExit), strings.Join(common.ExitActions, ", "))
+		}
+		for _, name := range services {
+			svc := t.services[name]
+			fmt.Fprintf(&b, "\n[%s.services.%s]\n", tomlKey(t.name), tomlKey(name))
+			fmt.Fprintf(&b, "watch = %s\n", tomlList(svc.Watch))
+			fmt.Fprintf(&b, "ignore = %s\n", tomlList(svc.Ignore))
+			if len(svc.Sync) > 0 {
+				fmt.Fprintf(&b, "sync = %s # copied into the container instead of restarting it\n", tomlTable(svc.Sync))
+			}
+			if svc.SyncCommand != "" {
+				fmt.Fprintf(&b, "sync_command = %s\n", tomlQuote(svc.SyncCommand))
+			}
+		}
+		return b.String()
+	}
+	fmt.Fprintf(&b, "build = %s # build shell commands\n", tomlList(t.build))
+	fmt.Fprintf(&b, "run = %s\n", tomlQuote(t.run))
+	return b.String()
+}
+
+// renderDocker writes the keys of a Dockerfile workflow
+func (t initTemplate) renderDocker(b *strings.Builder) {
+	fmt.Fprintf(b, "dockerfile = %s # built & run as a single container\n", tomlQuote(t.dockerfile))
+	if t.image != "" {
+		fmt.Fprintf(b, "image = %s\n", tomlQuote(t.image))
+	}
+	if t.container != "" {
+		fmt.Fprintf(b, "container = %s\n", tomlQuote(t.container))
+	}
+	fmt.Fprintf(b, "ports = %s # HOST:CONTAINER\n", tomlList(t.ports))
+	fmt.Fprintf(b, "volumes = %s # SOURCE:TARGET\n", tomlList(t.volumes))
+	if len(t.env) > 0 {
+		fmt.Fprintf(b, "env = %s\n", tomlTable(t.env))
+	}
+	if len(t.envFile) > 0 {
+		fmt.Fprintf(b, "env_file = %s\n", tomlList(t.envFile))
+	}
+	if t.engine != "" {
+		fmt.Fprintf(b, "engine = %s\n", tomlQuote(t.engine))
+	}
+	if t.noCache {
+		fmt.Fprintf(b, "no_cache = true\n")
+	}
+	if len(t.buildArgs) > 0 {
+		fmt.Fprintf(b, "build_args = %s\n", tomlTable(t.buildArgs))
+	}
+}
+
+// tomlKey quotes table names that aren't valid bare keys (like billing/api)
+func tomlKey(s string) string {
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+			return tomlQuote(s)
+		}
+	}
+	return s
+}
+
+func tomlQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+func tomlList(list []string) string {
+	if len(list) == 0 {
+		return "[ ]"
+	}
+
+	quoted := make([]string, len(list))
+	for i, s := range list {
+		quoted[i] = tomlQuote(s)
+	}
+	return fmt.Sprintf("[%s]", strings.Join(quoted, ", "))
+}
+
+// tomlTable renders a map as an inline table
+func tomlTable(m map[string]string) string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, key := range keys {
+		pairs[i] = fmt.Sprintf("%s = %s", tomlKey(key), tomlQuote(m[key]))
+	}
+	return fmt.Sprintf("{ %s }", strings.Join(pairs, ", "))
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reload/common"
+	"testing"
+)
+
+func TestGoTemplatePassesValidation(t *testing.T) {
+	// the run program only exists once the build created it
+	dir := t.TempDir()
+	file := filepath.Join(dir, common.ConfigFile)
+	if err := ioutil.WriteFile(file, []byte(initHeader+"\n"+goTemplate(dir).render()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	conf, err := common.LoadConfig(file)
+	if err != nil {
+		t.Fatalf("LoadConfig() error: %v", err)
+	}
+	wf, err := conf.Workflow("go", "")
+	if err != nil {
+		t.Fatalf("Workflow(go) error: %v", err)
+	}
+	if errs := wf.Check(); len(errs) > 0 {
+		t.Errorf("Check() = %v, want no errors", errs)
+	}
+
+	// without a build, a missing run program is still reported
+	wf.Build = nil
+	if errs := wf.Check(); len(errs) != 1 {
+		t.Errorf("Check() without build = %v, want the missing run program", errs)
+	}
+}
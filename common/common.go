@@ -7,6 +7,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/fsnotify/fsnotify"
@@ -68,12 +69,21 @@ func IsExcluded(path string, excludedPaths []string) bool {
 	return false
 }
 
-func StartProcess(cmd, dir string, isBuild, verbose bool) (*exec.Cmd, error) {
-	cmdSplit := strings.Split(cmd, " ")
-	prog, args := cmdSplit[0], cmdSplit[1:]
+func StartProcess(cmd, dir string, env []string, isBuild, verbose bool) (*exec.Cmd, error) {
+	return StartCommand(strings.Split(cmd, " "), dir, env, isBuild, verbose)
+}
+
+// StartCommand is StartProcess for a command that is already split into
+// arguments (so arguments can contain spaces)
+func StartCommand(cmd []string, dir string, env []string, isBuild, verbose bool) (*exec.Cmd, error) {
+	prog, args := cmd[0], cmd[1:]
 
 	c := exec.Command(prog, args...)
 	c.Dir = dir
+	if len(env) > 0 {
+		// workflow variables are added on top of reload's own environment
+		c.Env = append(os.Environ(), env...)
+	}
 
 	// set output to console
 	if verbose {
@@ -98,18 +108,117 @@ func StartProcess(cmd, dir string, isBuild, verbose bool) (*exec.Cmd, error) {
 	return c, nil
 }
 
+// CommandOutput runs a command to completion and returns what it printed
+// to stdout
+func CommandOutput(cmd []string, dir string, env []string) ([]byte, error) {
+	c := exec.Command(cmd[0], cmd[1:]...)
+	c.Dir = dir
+	if len(env) > 0 {
+		c.Env = append(os.Environ(), env...)
+	}
+	return c.Output()
+}
+
+// Resolve makes the watcher's path absolute. It's done once up front so
+// nothing depends on the working directory of the reload process.
+func (wc *WatcherConfig) Resolve() error {
+	path, err := filepath.Abs(wc.Path)
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	} else if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", path)
+	}
+
+	wc.Path = path
+	return nil
+}
+
+// Excludes checks a path against the ignore list, which is relative to the
+// watcher's path (absolute paths work too)
+func (wc *WatcherConfig) Excludes(path string) bool {
+	if IsExcluded(path, wc.Ignore) {
+		return true
+	}
+
+	rel, err := filepath.Rel(wc.Path, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return false
+	}
+	return IsExcluded(rel, wc.Ignore)
+}
+
+// MatchesAny checks a path (relative to the watcher's path) against globs.
+// A glob matches the path or any directory it is in, so "web" and "web/**"
+// both match web/static/app.js.
+func MatchesAny(rel string, patterns []string) bool {
+	for _, pattern := range patterns {
+		pattern = filepath.Clean(strings.TrimSuffix(pattern, "/**"))
+		for p := rel; p != "." && p != string(filepath.Separator); p = filepath.Dir(p) {
+			if ok, _ := filepath.Match(pattern, p); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// GlobBase returns the part of a glob before its first wildcard, which is
+// the directory (or file) that has to be watched for the glob to match
+func GlobBase(pattern string) string {
+	parts := strings.Split(filepath.Clean(pattern), string(filepath.Separator))
+	for i, part := range parts {
+		if strings.ContainsAny(part, "*?[") {
+			return filepath.Join(parts[:i]...)
+		}
+	}
+	return filepath.Join(parts...)
+}
+
+// StopProcess asks a run process to exit (SIGINT) and kills it if it is
+// still running after the grace period
+func StopProcess(c *exec.Cmd, grace time.Duration) {
+	if c == nil || c.Process == nil {
+		return
+	}
+
+	exited := make(chan struct{})
+	go func() {
+		c.Wait()
+		close(exited)
+	}()
+
+	if err := c.Process.Signal(os.Interrupt); err != nil {
+		// interrupts aren't supported everywhere (windows)
+		c.Process.Kill()
+	}
+	select {
+	case <-exited:
+	case <-time.After(grace):
+		c.Process.Kill()
+		<-exited
+	}
+}
+
 func AddToFileWatcher(w *fsnotify.Watcher, wc *WatcherConfig) error {
 	if len(wc.Watch) > 0 {
 		// watch specific files/directories
 		for _, file := range wc.Watch {
-			absPath := fmt.Sprintf("%s/%s", wc.Path, file)
-			if err := filepath.Walk(absPath, walkPath(w, &wc.Ignore)); err != nil {
+			absPath := file
+			if !filepath.IsAbs(file) {
+				absPath = filepath.Join(wc.Path, file)
+			}
+			if err := filepath.Walk(absPath, walkPath(w, wc)); err != nil {
 				return err
 			}
 		}
 	} else {
 		// just watch all files in the under the root path
-		if err := filepath.Walk(wc.Path, walkPath(w, &wc.Ignore)); err != nil {
+		if err := filepath.Walk(wc.Path, walkPath(w, wc)); err != nil {
 			return err
 		}
 	}
@@ -117,22 +226,28 @@ func AddToFileWatcher(w *fsnotify.Watcher, wc *WatcherConfig) error {
 	return nil
 }
 
-func walkPath(w *fsnotify.Watcher, ignoredPaths *[]string) func(string, os.FileInfo, error) error {
-	return func(path string, info os.FileInfo, _ error) error {
+func walkPath(w *fsnotify.Watcher, wc *WatcherConfig) func(string, os.FileInfo, error) error {
+	return func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+
 		// Git has a lot of sub directories, so just don't display anything if file watcher
-		// encounters a directory/file prefixed with .git
-		if strings.HasPrefix(path, ".git") || strings.Contains(path, "reload.toml") {
+		// encounters a .git directory
+		if info.Name() == ".git" && info.IsDir() {
+			return filepath.SkipDir
+		} else if IsConfigFile(info.Name()) {
 			return nil
-		} else if info.IsDir() && IsExcluded(path, *ignoredPaths) {
+		} else if info.IsDir() && wc.Excludes(path) {
 			log.Println(color.CyanString("🙉 ignoring %s", path))
-			return nil
-		} else if IsExcluded(path, *ignoredPaths) {
+			return filepath.SkipDir
+		} else if wc.Excludes(path) {
 			return nil
 		}
 
 		for _, ext := range autoExcludeFileExt {
 			if strings.HasSuffix(path, ext) {
-				*ignoredPaths = append(*ignoredPaths, path)
+				wc.Ignore = append(wc.Ignore, path)
 				return nil
 			}
 		}
@@ -0,0 +1,717 @@
+package common
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+const (
+	ConfigFile = "reload.toml"
+)
+
+// Workflow is a single workflow table in reload.toml
+type Workflow struct {
+	Name          string            `toml:"-" json:"-" yaml:"-"`
+	Description   string            `toml:"description" json:"description" yaml:"description"`
+	Containerized bool              `toml:"containerized" json:"containerized" yaml:"containerized"`
+	Verbose       bool              `toml:"verbose" json:"verbose" yaml:"verbose"`
+	Path          string            `toml:"path" json:"path" yaml:"path"`
+	Watch         []string          `toml:"watch" json:"watch" yaml:"watch"`
+	Ignore        []string          `toml:"ignore" json:"ignore" yaml:"ignore"`
+	Build         []string          `toml:"build" json:"build" yaml:"build"`
+	Run           string            `toml:"run" json:"run" yaml:"run"`
+	Service       string            `toml:"service" json:"service" yaml:"service"`
+	Env           map[string]string `toml:"env" json:"env" yaml:"env"`
+	EnvFile       []string          `toml:"env_file" json:"env_file" yaml:"env_file"`
+	Extends       string            `toml:"extends,omitempty" json:"extends,omitempty" yaml:"extends,omitempty"`
+	Merge         string            `toml:"merge" json:"merge" yaml:"merge"`
+	// compose services with their own watch & ignore globs
+	Services map[string]ComposeService `toml:"services,omitempty" json:"services,omitempty" yaml:"services,omitempty"`
+	// rebuild compose images before (re)starting services
+	Rebuild   bool              `toml:"rebuild,omitempty" json:"rebuild,omitempty" yaml:"rebuild,omitempty"`
+	BuildArgs map[string]string `toml:"build_args,omitempty" json:"build_args,omitempty" yaml:"build_args,omitempty"`
+	NoCache   bool              `toml:"no_cache,omitempty" json:"no_cache,omitempty" yaml:"no_cache,omitempty"`
+	// compose (or container) engine, detected from PATH when empty
+	Engine string `toml:"engine,omitempty" json:"engine,omitempty" yaml:"engine,omitempty"`
+	// containerized workflows with a dockerfile run a single container
+	Dockerfile string   `toml:"dockerfile,omitempty" json:"dockerfile,omitempty" yaml:"dockerfile,omitempty"`
+	Image      string   `toml:"image,omitempty" json:"image,omitempty" yaml:"image,omitempty"`
+	Container  string   `toml:"container,omitempty" json:"container,omitempty" yaml:"container,omitempty"`
+	Ports      []string `toml:"ports,omitempty" json:"ports,omitempty" yaml:"ports,omitempty"`
+	Volumes    []string `toml:"volumes,omitempty" json:"volumes,omitempty" yaml:"volumes,omitempty"`
+	// docker compose options shared by every compose command
+	ComposeFile     []string `toml:"compose_file,omitempty" json:"compose_file,omitempty" yaml:"compose_file,omitempty"`
+	Project         string   `toml:"project,omitempty" json:"project,omitempty" yaml:"project,omitempty"`
+	ComposeProfiles []string `toml:"compose_profiles,omitempty" json:"compose_profiles,omitempty" yaml:"compose_profiles,omitempty"`
+	ComposeEnvFile  []string `toml:"compose_env_file,omitempty" json:"compose_env_file,omitempty" yaml:"compose_env_file,omitempty"`
+	// what happens to the compose containers when reload exits (stop when empty)
+	OnExit string `toml:"on_exit,omitempty" json:"on_exit,omitempty" yaml:"on_exit,omitempty"`
+	// how long to wait for compose services to be healthy, like "90s" ("0" doesn't wait)
+	WaitTimeout string `toml:"wait_timeout,omitempty" json:"wait_timeout,omitempty" yaml:"wait_timeout,omitempty"`
+	// overlays picked with `reload start <workflow> --profile <name>`
+	Profiles map[string]*Workflow `toml:"profiles" json:"-" yaml:"-"`
+	// the profile applied to this workflow, if any
+	Profile string `toml:"-" json:"-" yaml:"-"`
+
+	// keys set in reload.toml (directly or inherited)
+	defined map[string]bool
+	// extended by other workflows, so it doesn't have to be runnable
+	template bool
+	// the workflow with each of its profiles applied
+	variants map[string]*Workflow
+	// variables that couldn't be expanded, reported when the workflow is used
+	// so the other workflows still work
+	err error
+}
+
+// Config is the decoded reload.toml file
+type Config struct {
+	// absolute path to the config file
+	File      string
+	Workflows map[string]*Workflow
+	// unknown keys, typos, etc. that don't stop reload from running
+	Warnings []string
+	// absolute paths of the config files pulled in with `include`
+	Includes []string
+
+	// include globs as written in the config file
+	include []string
+}
+
+// ConfigError points at the exact spot in reload.toml that is invalid
+type ConfigError struct {
+	Workflow string
+	Key      string
+	Line     int
+	Msg      string
+}
+
+func (e *ConfigError) Error() string {
+	var b strings.Builder
+	if e.Line > 0 {
+		fmt.Fprintf(&b, "line %d: ", e.Line)
+	}
+	if e.Workflow != "" {
+		fmt.Fprintf(&b, "workflow %s: ", e.Workflow)
+	}
+	if e.Key != "" {
+		fmt.Fprintf(&b, "key %s: ", e.Key)
+	}
+	b.WriteString(e.Msg)
+	return b.String()
+}
+
+// defaults for optional keys
+func newWorkflow(name string) *Workflow {
+	return &Workflow{
+		Name:    name,
+		Verbose: true,
+		Path:    ".",
+		Watch:   []string{},
+		Ignore:  []string{},
+		Build:   []string{},
+		Env:     map[string]string{},
+		EnvFile: []string{},
+		Merge:   MergeAppend,
+		defined: map[string]bool{},
+	}
+}
+
+// FindConfig looks for the nearest config file (reload.toml, reload.yaml,
+// reload.yml or reload.json) in dir and its parents
+func FindConfig(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+
+	for {
+		for _, name := range ConfigFiles {
+			file := filepath.Join(dir, name)
+			if info, err := os.Stat(file); err == nil && !info.IsDir() {
+				return file, nil
+			}
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", fmt.Errorf("no %s in %s or its parents: %w", ConfigFile, dir, os.ErrNotExist)
+		}
+		dir = parent
+	}
+}
+
+// LoadConfig reads and validates a reload.toml (or yaml/json) file and the
+// files it includes
+func LoadConfig(file string) (*Config, error) {
+	return loadConfig(file, map[string]bool{})
+}
+
+// loading holds the files being loaded, to catch include cycles
+func loadConfig(file string, loading map[string]bool) (*Config, error) {
+	format, err := ConfigFormat(file)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := filepath.Abs(filepath.Dir(file))
+	if err != nil {
+		return nil, err
+	}
+
+	conf, err := ParseConfigFormat(data, format, root)
+	if err != nil {
+		return nil, err
+	}
+	conf.File = filepath.Join(root, filepath.Base(file))
+
+	if len(conf.include) > 0 {
+		loading[conf.File] = true
+		defer delete(loading, conf.File)
+		if err := conf.loadIncludes(loading); err != nil {
+			return nil, err
+		}
+	}
+	return conf, nil
+}
+
+// ParseConfig decodes reload.toml data into typed workflows, root is the
+// directory of the config file (relative workflow paths are resolved from it)
+func ParseConfig(data []byte, root string) (*Config, error) {
+	return parseConfig(data, root, true)
+}
+
+// parseConfig decodes toml data, positions turns line numbers in errors on
+func parseConfig(data []byte, root string, positions bool) (*Config, error) {
+	src := string(data)
+	var lines []string
+	if positions {
+		lines = strings.Split(src, "\n")
+	}
+
+	var tables map[string]toml.Primitive
+	md, err := toml.Decode(src, &tables)
+	if err != nil {
+		if pe, ok := err.(toml.ParseError); ok {
+			msg := pe.Message
+			if msg == "" {
+				msg = strings.TrimPrefix(pe.Error(), fmt.Sprintf("toml: line %d: ", pe.Position.Line))
+			}
+			if !positions {
+				pe.Position.Line = 0
+			}
+			return nil, &ConfigError{Line: pe.Position.Line, Msg: msg}
+		}
+		return nil, &ConfigError{Msg: err.Error()}
+	}
+
+	// the raw values are only used to report type mismatches precisely
+	var raw map[string]interface{}
+	if _, err := toml.Decode(src, &raw); err != nil {
+		return nil, &ConfigError{Msg: err.Error()}
+	}
+
+	var include []string
+	parsed := map[string]*Workflow{}
+	for _, name := range sortedKeys(raw) {
+		// the only top-level key that isn't a workflow
+		if name == IncludeKey {
+			if err := checkType(reflect.TypeOf(include), raw[name]); err != nil {
+				return nil, &ConfigError{
+					Key:  name,
+					Line: keyLine(lines, "", name),
+					Msg:  err.Error(),
+				}
+			}
+			md.PrimitiveDecode(tables[name], &include)
+			continue
+		}
+
+		table, ok := raw[name].(map[string]interface{})
+		if !ok {
+			return nil, &ConfigError{
+				Key:  name,
+				Line: keyLine(lines, "", name),
+				Msg:  fmt.Sprintf("expected a workflow table, got %s", tomlTypeName(raw[name])),
+			}
+		}
+
+		if err := checkTypes(name, table, lines); err != nil {
+			return nil, err
+		}
+
+		wf := &Workflow{Name: name, defined: map[string]bool{}}
+		if err := md.PrimitiveDecode(tables[name], wf); err != nil {
+			return nil, &ConfigError{Workflow: name, Line: keyLine(lines, name, ""), Msg: err.Error()}
+		}
+		for key := range table {
+			wf.defined[key] = true
+		}
+		// profiles only know the keys they set, everything else comes from the workflow
+		profiles, _ := table["profiles"].(map[string]interface{})
+		for profile, keys := range profiles {
+			overlay := wf.Profiles[profile]
+			if overlay == nil {
+				overlay = &Workflow{}
+				wf.Profiles[profile] = overlay
+			}
+			overlay.Name, overlay.defined = name, map[string]bool{}
+			for key := range keys.(map[string]interface{}) {
+				overlay.defined[key] = true
+			}
+		}
+
+		parsed[name] = wf
+	}
+
+	// fill in [defaults] and extended workflows
+	conf, err := resolveWorkflows(parsed, lines, root)
+	if err != nil {
+		return nil, err
+	}
+	conf.include = include
+
+	for _, key := range md.Undecoded() {
+		conf.Warnings = append(conf.Warnings, undecodedWarning(key, lines))
+	}
+
+	return conf, nil
+}
+
+// Names returns the workflow names in alphabetical order
+func (c *Config) Names() []string {
+	return sortedNames(c.Workflows)
+}
+
+// Workflow looks up a workflow with a profile applied (an empty profile is
+// the workflow as is)
+func (c *Config) Workflow(name, profile string) (*Workflow, error) {
+	wf, ok := c.Workflows[name]
+	if !ok {
+		return nil, fmt.Errorf("could not find workflow %s", name)
+	}
+	if profile == "" {
+		return wf, wf.err
+	}
+
+	variant, ok := wf.variants[profile]
+	if !ok {
+		if len(wf.variants) == 0 {
+			return nil, fmt.Errorf("workflow %s has no profiles", name)
+		}
+		return nil, fmt.Errorf(
+			"workflow %s has no profile %s (expected one of %s)",
+			name,
+			profile,
+			strings.Join(wf.ProfileNames(), ", "),
+		)
+	}
+	return variant, variant.err
+}
+
+// ProfileNames returns the workflow's profiles in alphabetical order
+func (wf *Workflow) ProfileNames() []string {
+	return sortedNames(wf.variants)
+}
+
+// Type is "basic", "compose" or "docker"
+func (wf *Workflow) Type() string {
+	if wf.Containerized && wf.Dockerfile != "" {
+		return "docker"
+	} else if wf.Containerized {
+		return "compose"
+	}
+	return "basic"
+}
+
+// RootFlags converts a basic workflow to the flags used by the root command
+func (wf *Workflow) RootFlags() RootFlags {
+	return RootFlags{
+		WC:       wf.watcherConfig(),
+		Build:    wf.Build,
+		Run:      wf.Run,
+		Verbose:  wf.Verbose,
+		Env:      wf.Env,
+		EnvFiles: wf.EnvFile,
+	}
+}
+
+// ComposeFlags converts a containerized workflow to the flags used by the compose command
+func (wf *Workflow) ComposeFlags() ComposeFlags {
+	services := map[string]ComposeService{}
+	for name, svc := range wf.Services {
+		services[name] = svc
+	}
+	// the service key is a service that reloads on any change
+	if _, ok := services[wf.Service]; wf.Service != "" && !ok {
+		services[wf.Service] = ComposeService{}
+	}
+
+	return ComposeFlags{
+		WC:              wf.watcherConfig(),
+		Services:        services,
+		Rebuild:         wf.Rebuild,
+		BuildArgs:       wf.BuildArgs,
+		NoCache:         wf.NoCache,
+		Engine:          wf.Engine,
+		Files:           wf.ComposeFile,
+		Project:         wf.Project,
+		Profiles:        wf.ComposeProfiles,
+		ComposeEnvFiles: wf.ComposeEnvFile,
+		OnExit:          wf.OnExit,
+		WaitTimeout:     wf.waitTimeout(),
+		Verbose:         wf.Verbose,
+		Env:             wf.Env,
+		EnvFiles:        wf.EnvFile,
+	}
+}
+
+// waitTimeout parses wait_timeout (checked by validate), empty is the default
+func (wf *Workflow) waitTimeout() time.Duration {
+	if wf.WaitTimeout == "" {
+		return DefaultWaitTimeout
+	}
+	timeout, _ := time.ParseDuration(wf.WaitTimeout)
+	return timeout
+}
+
+// DockerFlags converts a containerized workflow with a dockerfile to the flags
+// used by the docker command
+func (wf *Workflow) DockerFlags() DockerFlags {
+	return DockerFlags{
+		WC:         wf.watcherConfig(),
+		Name:       wf.Name,
+		Engine:     wf.Engine,
+		Dockerfile: wf.Dockerfile,
+		Image:      wf.Image,
+		Container:  wf.Container,
+		Ports:      wf.Ports,
+		Volumes:    wf.Volumes,
+		BuildArgs:  wf.BuildArgs,
+		NoCache:    wf.NoCache,
+		Verbose:    wf.Verbose,
+		Env:        wf.Env,
+		EnvFiles:   wf.EnvFile,
+	}
+}
+
+func (wf *Workflow) watcherConfig() WatcherConfig {
+	return WatcherConfig{
+		Path:   wf.Path,
+		Watch:  append([]string{}, wf.Watch...),
+		Ignore: append([]string{}, wf.Ignore...),
+	}
+}
+
+func (wf *Workflow) validate(lines []string) error {
+	if wf.Merge != MergeAppend && wf.Merge != MergeReplace {
+		return &ConfigError{
+			Workflow: wf.Name,
+			Key:      "merge",
+			Line:     keyLine(lines, wf.Name, "merge"),
+			Msg:      fmt.Sprintf("expected %q or %q, got %q", MergeAppend, MergeReplace, wf.Merge),
+		}
+	}
+	engines, isEngine := ComposeEngines, IsComposeEngine
+	if wf.Type() == "docker" {
+		engines, isEngine = ContainerEngines, IsContainerEngine
+	}
+	if wf.Engine != "" && !isEngine(wf.Engine) {
+		return &ConfigError{
+			Workflow: wf.Name,
+			Key:      "engine",
+			Line:     keyLine(lines, wf.Name, "engine"),
+			Msg:      fmt.Sprintf("expected one of %s, got %q", strings.Join(engines, ", "), wf.Engine),
+		}
+	}
+	if timeout, err := time.ParseDuration(wf.WaitTimeout); wf.WaitTimeout != "" && (err != nil || timeout < 0) {
+		return &ConfigError{
+			Workflow: wf.Name,
+			Key:      "wait_timeout",
+			Line:     keyLine(lines, wf.Name, "wait_timeout"),
+			Msg:      fmt.Sprintf("expected a duration like \"90s\" or \"2m\", got %q", wf.WaitTimeout),
+		}
+	}
+	if wf.OnExit != "" && !IsExitAction(wf.OnExit) {
+		return &ConfigError{
+			Workflow: wf.Name,
+			Key:      "on_exit",
+			Line:     keyLine(lines, wf.Name, "on_exit"),
+			Msg:      fmt.Sprintf("expected one of %s, got %q", strings.Join(ExitActions, ", "), wf.OnExit),
+		}
+	}
+
+	return nil
+}
+
+// checkTypes compares every known key against the type of its struct field
+func checkTypes(name string, table map[string]interface{}, lines []string) error {
+	t := reflect.TypeOf(Workflow{})
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key := tomlKey(field)
+		val, ok := table[key]
+		if key == "-" || !ok {
+			continue
+		}
+		if key == "profiles" {
+			if err := checkProfiles(name, val, lines); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := checkType(field.Type, val); err != nil {
+			err = err.in(key)
+			return &ConfigError{
+				Workflow: name,
+				Key:      err.path,
+				Line:     pathLine(lines, name, err.path),
+				Msg:      err.Error(),
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkProfiles makes sure every [workflow.profiles.name] table only sets
+// workflow keys
+func checkProfiles(name string, val interface{}, lines []string) error {
+	profiles, ok := val.(map[string]interface{})
+	if !ok {
+		return &ConfigError{
+			Workflow: name,
+			Key:      "profiles",
+			Line:     keyLine(lines, name, "profiles"),
+			Msg:      fmt.Sprintf("expected a table of profiles, got %s", tomlTypeName(val)),
+		}
+	}
+
+	for _, profile := range sortedKeys(profiles) {
+		table := name + ".profiles." + profile
+		keys, ok := profiles[profile].(map[string]interface{})
+		if !ok {
+			return &ConfigError{
+				Workflow: name,
+				Key:      "profiles." + profile,
+				Line:     keyLine(lines, name, "profiles"),
+				Msg:      fmt.Sprintf("expected a profile table, got %s", tomlTypeName(profiles[profile])),
+			}
+		}
+		for _, key := range []string{"profiles", "extends"} {
+			if _, ok := keys[key]; ok {
+				return &ConfigError{
+					Workflow: table,
+					Key:      key,
+					Line:     keyLine(lines, table, key),
+					Msg:      fmt.Sprintf("profiles can't set %s", key),
+				}
+			}
+		}
+		if err := checkTypes(table, keys, lines); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// typeError is the first value that doesn't match the type of its field
+type typeError struct {
+	// key path relative to the checked value, empty for the value itself
+	path     string
+	expected string
+	got      interface{}
+}
+
+func (e *typeError) Error() string {
+	return fmt.Sprintf("expected %s, got %s", e.expected, tomlTypeName(e.got))
+}
+
+// in prefixes the path with the key of the table holding the value
+func (e *typeError) in(key string) *typeError {
+	if e.path != "" {
+		key += "." + e.path
+	}
+	return &typeError{path: key, expected: e.expected, got: e.got}
+}
+
+// checkType recurses into tables, so a wrong value in env or services is
+// reported with its own key and type
+func checkType(t reflect.Type, val interface{}) *typeError {
+	switch t.Kind() {
+	case reflect.String:
+		if _, ok := val.(string); !ok {
+			return &typeError{expected: "string", got: val}
+		}
+	case reflect.Bool:
+		if _, ok := val.(bool); !ok {
+			return &typeError{expected: "boolean", got: val}
+		}
+	case reflect.Slice:
+		expected := fmt.Sprintf("array of %ss", tomlKindName(t.Elem().Kind()))
+		items, ok := val.([]interface{})
+		if !ok {
+			return &typeError{expected: expected, got: val}
+		}
+		for _, item := range items {
+			if err := checkType(t.Elem(), item); err != nil {
+				return &typeError{expected: expected, got: val}
+			}
+		}
+	case reflect.Map:
+		table, ok := val.(map[string]interface{})
+		if !ok {
+			return &typeError{expected: fmt.Sprintf("table of %ss", tomlKindName(t.Elem().Kind())), got: val}
+		}
+		for _, key := range sortedKeys(table) {
+			if err := checkType(t.Elem(), table[key]); err != nil {
+				return err.in(key)
+			}
+		}
+	case reflect.Struct:
+		table, ok := val.(map[string]interface{})
+		if !ok {
+			return &typeError{expected: "table", got: val}
+		}
+		for i := 0; i < t.NumField(); i++ {
+			key := tomlKey(t.Field(i))
+			item, ok := table[key]
+			if !ok {
+				continue
+			}
+			if err := checkType(t.Field(i).Type, item); err != nil {
+				return err.in(key)
+			}
+		}
+	}
+
+	return nil
+}
+
+// tomlKey is the key of a field in reload.toml, without options like omitempty
+func tomlKey(field reflect.StructField) string {
+	return strings.SplitN(field.Tag.Get("toml"), ",", 2)[0]
+}
+
+func tomlKindName(k reflect.Kind) string {
+	switch k {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Struct:
+		return "table"
+	}
+	return k.String()
+}
+
+func tomlTypeName(val interface{}) string {
+	switch v := val.(type) {
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case int64:
+		return "integer"
+	case float64:
+		return "float"
+	case time.Time:
+		return "datetime"
+	case map[string]interface{}:
+		return "table"
+	case []map[string]interface{}:
+		return "array of tables"
+	case []interface{}:
+		for _, item := range v {
+			if _, ok := item.(string); !ok {
+				return fmt.Sprintf("array containing %s", tomlTypeName(item))
+			}
+		}
+		return "array"
+	}
+	return fmt.Sprintf("%T", val)
+}
+
+func undecodedWarning(key toml.Key, lines []string) string {
+	table, name := "", key.String()
+	if len(key) > 1 {
+		table = strings.Join(key[:len(key)-1], ".")
+		name = key[len(key)-1]
+	}
+
+	msg := fmt.Sprintf("unknown key %s", name)
+	if table != "" {
+		msg = fmt.Sprintf("%s in workflow %s", msg, table)
+	}
+	if line := keyLine(lines, table, name); line > 0 {
+		msg = fmt.Sprintf("line %d: %s", line, msg)
+	}
+	return msg
+}
+
+// keyLine finds the line a key is defined on (1-indexed, 0 if unknown).
+// An empty key returns the line of the table header.
+func keyLine(lines []string, table, key string) int {
+	current := ""
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") {
+			current = strings.Trim(strings.SplitN(line, "]", 2)[0], "[ ")
+			if key == "" && current == table {
+				return i + 1
+			}
+			continue
+		}
+
+		if current != table || key == "" {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) == 2 && strings.Trim(strings.TrimSpace(parts[0]), `"'`) == key {
+			return i + 1
+		}
+	}
+
+	return 0
+}
+
+// pathLine finds the line of a nested key like services.web.watch, which can
+// be set in its own [workflow.services.web] table, as a dotted key or inside
+// an inline table (the line of the outermost key that is found)
+func pathLine(lines []string, table, path string) int {
+	parts := strings.Split(path, ".")
+	for end := len(parts); end > 0; end-- {
+		for i := end - 1; i >= 0; i-- {
+			sub := strings.Join(append([]string{table}, parts[:i]...), ".")
+			if line := keyLine(lines, sub, strings.Join(parts[i:end], ".")); line > 0 {
+				return line
+			}
+		}
+	}
+
+	return 0
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,61 @@
+package common
+
+import (
+	"testing"
+)
+
+func TestTypeErrorsNameTheNestedKey(t *testing.T) {
+	tests := []struct {
+		config string
+		want   string
+	}{
+		{
+			config: `
+[c]
+run = "app"
+env = { PORT = 8080 }
+`,
+			want: "line 4: workflow c: key env.PORT: expected string, got integer",
+		},
+		{
+			config: `
+[c]
+run = "app"
+env.PORT = 8080
+`,
+			want: "line 4: workflow c: key env.PORT: expected string, got integer",
+		},
+		{
+			config: `
+[c]
+service = "web"
+
+[c.services.web]
+ignore = ["tmp"]
+watch = "web"
+`,
+			want: "line 7: workflow c: key services.web.watch: expected array of strings, got string",
+		},
+		{
+			config: `
+[c]
+service = "web"
+services = { web = "web" }
+`,
+			want: "line 4: workflow c: key services.web: expected table, got string",
+		},
+		{
+			config: `
+include = "other.toml"
+`,
+			want: "line 2: key include: expected array of strings, got string",
+		},
+	}
+
+	for _, tt := range tests {
+		_, err := ParseConfig([]byte(tt.config), t.TempDir())
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("ParseConfig(%q) error = %v, want %q", tt.config, err, tt.want)
+		}
+	}
+}
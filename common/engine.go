@@ -0,0 +1,76 @@
+package common
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+var (
+	// compose engines reload can use, in the order they're detected
+	ComposeEngines = []string{"docker compose", "docker-compose", "podman compose", "podman-compose"}
+	// engines that build & run Dockerfiles
+	ContainerEngines = []string{"docker", "podman"}
+)
+
+// IsComposeEngine reports whether engine is one of the known compose engines
+func IsComposeEngine(engine string) bool {
+	for _, e := range ComposeEngines {
+		if engine == e {
+			return true
+		}
+	}
+	return false
+}
+
+// IsContainerEngine reports whether engine is one of the known container engines
+func IsContainerEngine(engine string) bool {
+	for _, e := range ContainerEngines {
+		if engine == e {
+			return true
+		}
+	}
+	return false
+}
+
+// DetectContainerEngine returns the first container engine on PATH
+func DetectContainerEngine() (string, error) {
+	for _, engine := range ContainerEngines {
+		if _, err := exec.LookPath(engine); err == nil {
+			return engine, nil
+		}
+	}
+	return "", fmt.Errorf(
+		"no container engine found, install one of %s (or pick one with engine/--engine)",
+		strings.Join(ContainerEngines, ", "),
+	)
+}
+
+// DetectComposeEngine returns the first compose engine available on PATH
+func DetectComposeEngine() (string, error) {
+	for _, engine := range ComposeEngines {
+		if ComposeEngineWorks(engine) {
+			return engine, nil
+		}
+	}
+	return "", fmt.Errorf(
+		"no compose engine found, install one of %s (or pick one with engine/--engine)",
+		strings.Join(ComposeEngines, ", "),
+	)
+}
+
+// ComposeEngineWorks checks that an engine's program is on PATH. Engines that
+// are a plugin (docker compose, podman compose) have to answer `version` too.
+func ComposeEngineWorks(engine string) bool {
+	fields := strings.Fields(engine)
+	if len(fields) == 0 {
+		return false
+	}
+	if _, err := exec.LookPath(fields[0]); err != nil {
+		return false
+	}
+	if len(fields) == 1 {
+		return true
+	}
+	return exec.Command(fields[0], append(fields[1:], "version")...).Run() == nil
+}
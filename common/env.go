@@ -0,0 +1,178 @@
+package common
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/fsnotify/fsnotify"
+)
+
+// ReadEnvFile parses a .env file (KEY=VALUE lines, # comments, optional
+// `export` prefix and quoted values)
+func ReadEnvFile(file string) (map[string]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	env := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		parts := strings.SplitN(line, "=", 2)
+		key := strings.TrimSpace(parts[0])
+		if len(parts) != 2 || key == "" {
+			return nil, fmt.Errorf("%s:%d: expected KEY=VALUE", file, n)
+		}
+		env[key] = unquoteEnvValue(strings.TrimSpace(parts[1]))
+	}
+
+	return env, scanner.Err()
+}
+
+func unquoteEnvValue(val string) string {
+	if len(val) >= 2 && (val[0] == '"' || val[0] == '\'') {
+		// trailing comments after the closing quote
+		if i := strings.LastIndexByte(val, val[0]); i > 0 {
+			val = val[:i+1]
+		}
+	}
+	if len(val) >= 2 {
+		switch {
+		case val[0] == '"' && val[len(val)-1] == '"':
+			return strings.NewReplacer(`\n`, "\n", `\"`, `"`, `\\`, `\`).Replace(val[1 : len(val)-1])
+		case val[0] == '\'' && val[len(val)-1] == '\'':
+			return val[1 : len(val)-1]
+		}
+	}
+
+	// strip trailing comments
+	if i := strings.Index(val, " #"); i >= 0 {
+		val = strings.TrimSpace(val[:i])
+	}
+	return val
+}
+
+// Environ builds the KEY=VALUE pairs added to a workflow's processes. Env
+// files are read in order (later files win) and the env table wins over all
+// of them. Env files are re-read on every call so edits are picked up.
+func Environ(dir string, env map[string]string, files []string) []string {
+	vars := map[string]string{}
+	for _, file := range EnvFilePaths(dir, files) {
+		fileVars, err := ReadEnvFile(file)
+		if os.IsNotExist(err) {
+			LogWarning(fmt.Sprintf("env file %s does not exist, skipping it", file))
+			continue
+		} else if err != nil {
+			LogWarning(fmt.Sprintf("failed to read env file: %+v", err))
+			continue
+		}
+		for key, val := range fileVars {
+			vars[key] = val
+		}
+	}
+	for key, val := range env {
+		vars[key] = val
+	}
+
+	pairs := make([]string, 0, len(vars))
+	for key, val := range vars {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", key, val))
+	}
+	sort.Strings(pairs)
+	return pairs
+}
+
+// EnvFilePaths resolves env files relative to the workflow's path
+func EnvFilePaths(dir string, files []string) []string {
+	paths := make([]string, len(files))
+	for i, file := range files {
+		if filepath.IsAbs(file) {
+			paths[i] = filepath.Clean(file)
+		} else {
+			paths[i] = filepath.Join(dir, file)
+		}
+	}
+	return paths
+}
+
+// IsEnvFile reports whether a file event belongs to one of the env files
+func IsEnvFile(name, dir string, files []string) bool {
+	name = filepath.Clean(name)
+	for _, file := range EnvFilePaths(dir, files) {
+		if name == file {
+			return true
+		}
+	}
+	return false
+}
+
+// WatchEnvFiles adds the directories of env files to the watcher when they
+// aren't watched already. They are excluded from rebuilds by default, but a
+// change to them still needs a restart. Watching the directory picks up env
+// files created later and editors that save by renaming over the file.
+func WatchEnvFiles(w *fsnotify.Watcher, wc *WatcherConfig, files []string) {
+	for _, file := range EnvFilePaths(wc.Path, files) {
+		dir := filepath.Dir(file)
+		if watchesDir(wc, dir) {
+			continue
+		}
+		if err := w.Add(dir); err != nil {
+			LogWarning(fmt.Sprintf("can't watch %s for env file changes", dir))
+			continue
+		}
+		if wc.envDirs == nil {
+			wc.envDirs = map[string]bool{}
+		}
+		wc.envDirs[dir] = true
+		log.Println(color.CyanString("🌱 listening to %s", file))
+	}
+}
+
+// envOnly reports whether a file event comes from a directory that is only
+// watched for its env files (and the file itself isn't watched)
+func (wc *WatcherConfig) envOnly(name string) bool {
+	if !wc.envDirs[filepath.Dir(name)] {
+		return false
+	}
+	for _, file := range wc.Watch {
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(wc.Path, file)
+		}
+		if filepath.Clean(file) == name {
+			return false
+		}
+	}
+	return true
+}
+
+// watchesDir reports whether AddToFileWatcher watches a directory
+func watchesDir(wc *WatcherConfig, dir string) bool {
+	rel, err := filepath.Rel(wc.Path, dir)
+	if err != nil || strings.HasPrefix(rel, "..") || wc.Excludes(dir) {
+		return false
+	}
+	if len(wc.Watch) == 0 {
+		return true
+	}
+
+	for _, file := range wc.Watch {
+		file = filepath.Clean(file)
+		if rel == file || strings.HasPrefix(rel, file+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+package common
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+const (
+	// what happens to the containers of a compose workflow when reload exits
+	ExitStop        = "stop"
+	ExitDown        = "down"
+	ExitDownVolumes = "down-volumes"
+	ExitNone        = "none"
+)
+
+// ExitActions are the valid values of on_exit (stop when empty)
+var ExitActions = []string{ExitStop, ExitDown, ExitDownVolumes, ExitNone}
+
+// IsExitAction reports whether action is a valid on_exit value
+func IsExitAction(action string) bool {
+	for _, a := range ExitActions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	exitMu    sync.Mutex
+	exitHooks = map[int]func(){}
+	exitID    int
+	exiting   bool
+)
+
+// OnExit runs fn when reload exits on Ctrl-C (or SIGTERM) or a fatal error.
+// The returned func removes the hook, e.g. once the workflow has stopped.
+func OnExit(fn func()) func() {
+	exitMu.Lock()
+	defer exitMu.Unlock()
+
+	exitID++
+	id := exitID
+	exitHooks[id] = fn
+	return func() {
+		exitMu.Lock()
+		defer exitMu.Unlock()
+		delete(exitHooks, id)
+	}
+}
+
+// Exit runs the exit hooks (once) and exits with code. Fatal errors in other
+// goroutines wait for the hooks to finish instead of cutting them short.
+func Exit(code int) {
+	exitMu.Lock()
+	if exiting {
+		exitMu.Unlock()
+		select {}
+	}
+	exiting = true
+	hooks := make([]func(), 0, len(exitHooks))
+	for _, fn := range exitHooks {
+		hooks = append(hooks, fn)
+	}
+	exitMu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, fn := range hooks {
+		wg.Add(1)
+		go func(fn func()) {
+			defer wg.Done()
+			fn()
+		}(fn)
+	}
+	wg.Wait()
+	os.Exit(code)
+}
+
+// HandleSignals cleans up with the exit hooks on Ctrl-C (or SIGTERM), a
+// second Ctrl-C exits right away
+func HandleSignals() {
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		sig := <-signals
+		code := 130
+		if sig == syscall.SIGTERM {
+			code = 143
+		}
+		log.Printf("👋 %s (press Ctrl-C again to quit right away)", HiYlw("cleaning up..."))
+		go Exit(code)
+
+		<-signals
+		os.Exit(code)
+	}()
+}
@@ -0,0 +1,110 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	FormatTOML = "toml"
+	FormatYAML = "yaml"
+	FormatJSON = "json"
+)
+
+var (
+	// config files reload looks for, in order of preference
+	ConfigFiles = []string{ConfigFile, "reload.yaml", "reload.yml", "reload.json"}
+)
+
+// ConfigFormat picks the format of a config file from its extension
+func ConfigFormat(file string) (string, error) {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".toml":
+		return FormatTOML, nil
+	case ".yaml", ".yml":
+		return FormatYAML, nil
+	case ".json":
+		return FormatJSON, nil
+	}
+	return "", fmt.Errorf("unsupported config file %s (expected .toml, .yaml, .yml or .json)", file)
+}
+
+// IsConfigFile reports whether a file name is one of the default config files
+func IsConfigFile(name string) bool {
+	for _, file := range ConfigFiles {
+		if name == file {
+			return true
+		}
+	}
+	return false
+}
+
+// DecodeRaw decodes a config file as is: no defaults, inheritance or variables
+func DecodeRaw(data []byte, format string) (map[string]interface{}, error) {
+	raw := map[string]interface{}{}
+	var err error
+	switch format {
+	case FormatTOML:
+		_, err = toml.Decode(string(data), &raw)
+	case FormatYAML:
+		err = yaml.Unmarshal(data, &raw)
+	case FormatJSON:
+		err = json.Unmarshal(data, &raw)
+	default:
+		err = fmt.Errorf("unknown format %s", format)
+	}
+
+	return raw, err
+}
+
+// EncodeRaw writes a decoded config file in another format
+func EncodeRaw(w io.Writer, raw interface{}, format string) error {
+	switch format {
+	case FormatTOML:
+		enc := toml.NewEncoder(w)
+		enc.Indent = ""
+		return enc.Encode(raw)
+	case FormatYAML:
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		defer enc.Close()
+		return enc.Encode(raw)
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(raw)
+	}
+
+	return fmt.Errorf("unknown format %s (expected toml, yaml or json)", format)
+}
+
+// ParseConfigFormat is ParseConfig for a config file in any supported format
+func ParseConfigFormat(data []byte, format, root string) (*Config, error) {
+	if format == FormatTOML {
+		return ParseConfig(data, root)
+	}
+	return parseOtherFormat(data, format, root)
+}
+
+// parseOtherFormat validates yaml & json configs with the same model as
+// reload.toml by converting them to toml first. Line numbers only make sense
+// for the original file, so they're left out.
+func parseOtherFormat(data []byte, format, root string) (*Config, error) {
+	raw, err := DecodeRaw(data, format)
+	if err != nil {
+		return nil, &ConfigError{Msg: err.Error()}
+	}
+
+	var buf bytes.Buffer
+	if err := EncodeRaw(&buf, raw, FormatTOML); err != nil {
+		return nil, &ConfigError{Msg: err.Error()}
+	}
+	return parseConfig(buf.Bytes(), root, false)
+}
@@ -0,0 +1,157 @@
+package common
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// how often a deferred reload checks if git is done
+	GitPollInterval = 250 * time.Millisecond
+	// how long the working tree has to be quiet before reloading
+	gitSettleTime = 750 * time.Millisecond
+)
+
+var (
+	// files/directories git creates while it rewrites the working tree
+	gitBusyMarkers = []string{
+		"index.lock",
+		"HEAD.lock",
+		"rebase-merge",
+		"rebase-apply",
+	}
+)
+
+// GitGuard holds back reloads while a git operation (checkout, rebase,
+// stash pop, ...) is changing files in the repository
+type GitGuard struct {
+	gitDir   string
+	head     string
+	pending  int
+	lastSeen time.Time
+}
+
+// NewGitGuard finds the git repository containing path. A nil guard is
+// returned (and is safe to use) when path isn't inside a repository.
+func NewGitGuard(path string) *GitGuard {
+	gitDir := findGitDir(path)
+	if gitDir == "" {
+		return nil
+	}
+
+	g := &GitGuard{gitDir: gitDir}
+	g.head = g.readHead()
+	return g
+}
+
+// Hold reports whether a file change should be deferred. Once a change has
+// been held, every following change is held until Release succeeds.
+func (g *GitGuard) Hold() bool {
+	if g == nil {
+		return false
+	}
+
+	if g.pending == 0 && !g.busy() {
+		return false
+	}
+
+	if g.pending == 0 {
+		log.Printf("⏸️  %s", HiYlw("git operation in progress, pausing reload..."))
+	}
+	g.pending++
+	g.lastSeen = time.Now()
+	return true
+}
+
+// Release reports whether the repository is quiet again, along with the
+// number of changes that were held back
+func (g *GitGuard) Release() (int, bool) {
+	if g == nil || g.pending == 0 {
+		return 0, false
+	}
+
+	if g.busy() || time.Since(g.lastSeen) < gitSettleTime {
+		return 0, false
+	}
+
+	n := g.pending
+	g.pending = 0
+	return n, true
+}
+
+func (g *GitGuard) busy() bool {
+	for _, marker := range gitBusyMarkers {
+		if _, err := os.Stat(filepath.Join(g.gitDir, marker)); err == nil {
+			g.lastSeen = time.Now()
+			return true
+		}
+	}
+
+	// a moved HEAD means a checkout just happened (or is happening), unless it
+	// moved a while ago without touching any watched file
+	if head := g.readHead(); head != g.head {
+		g.head = head
+		info, err := os.Stat(filepath.Join(g.gitDir, "HEAD"))
+		if err == nil && time.Since(info.ModTime()) < gitSettleTime {
+			g.lastSeen = time.Now()
+			return true
+		}
+	}
+
+	return false
+}
+
+func (g *GitGuard) readHead() string {
+	data, err := ioutil.ReadFile(filepath.Join(g.gitDir, "HEAD"))
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(data))
+}
+
+func findGitDir(path string) string {
+	dir, err := filepath.Abs(path)
+	if err != nil {
+		return ""
+	}
+
+	for {
+		gitPath := filepath.Join(dir, ".git")
+		if info, err := os.Stat(gitPath); err == nil {
+			if info.IsDir() {
+				return gitPath
+			}
+			// worktrees & submodules use a .git file pointing to the real directory
+			return readGitFile(dir, gitPath)
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+func readGitFile(dir, gitPath string) string {
+	data, err := ioutil.ReadFile(gitPath)
+	if err != nil {
+		return ""
+	}
+
+	line := strings.TrimSpace(string(data))
+	if !strings.HasPrefix(line, "gitdir:") {
+		return ""
+	}
+
+	gitDir := strings.TrimSpace(strings.TrimPrefix(line, "gitdir:"))
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(dir, gitDir)
+	}
+	return gitDir
+}
@@ -0,0 +1,40 @@
+package common
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestGitGuardIgnoresOldHeadMoves(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reload-git")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	head := filepath.Join(dir, ".git", "HEAD")
+	os.MkdirAll(filepath.Dir(head), 0755)
+	ioutil.WriteFile(head, []byte("ref: refs/heads/main\n"), 0644)
+
+	g := NewGitGuard(dir)
+	if g == nil {
+		t.Fatal("expected a guard inside a repository")
+	}
+
+	// a checkout that changed no watched files, followed by an edit later on
+	ioutil.WriteFile(head, []byte("ref: refs/heads/copy\n"), 0644)
+	old := time.Now().Add(-time.Minute)
+	os.Chtimes(head, old, old)
+	if g.Hold() {
+		t.Fatal("an edit long after a checkout was held")
+	}
+
+	// a checkout that is changing files right now
+	ioutil.WriteFile(head, []byte("ref: refs/heads/main\n"), 0644)
+	if !g.Hold() {
+		t.Fatal("an edit during a checkout wasn't held")
+	}
+}
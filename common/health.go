@@ -0,0 +1,92 @@
+package common
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const (
+	// how long compose services get to be running & healthy after (re)starting
+	DefaultWaitTimeout = 60 * time.Second
+)
+
+// ServiceStatus is a container in the output of `docker compose ps --format json`
+type ServiceStatus struct {
+	Name    string `json:"Name"`
+	Service string `json:"Service"`
+	// created, running, restarting, exited, dead...
+	State string `json:"State"`
+	// empty without a healthcheck, otherwise starting, healthy or unhealthy
+	Health   string `json:"Health"`
+	ExitCode int    `json:"ExitCode"`
+}
+
+// Ready reports whether the container is running and healthy (when it has a
+// healthcheck), or is a one-off container that exited successfully
+func (s ServiceStatus) Ready() bool {
+	switch s.State {
+	case "running":
+		return s.Health == "" || s.Health == "healthy"
+	case "exited":
+		return s.ExitCode == 0
+	}
+	return false
+}
+
+// Failed reports whether the container won't become ready without a reload
+func (s ServiceStatus) Failed() bool {
+	switch {
+	case s.Health == "unhealthy":
+		return true
+	case s.State == "exited":
+		return s.ExitCode != 0
+	case s.State == "dead":
+		return true
+	}
+	return false
+}
+
+// Status describes the container's state for the logs
+func (s ServiceStatus) Status() string {
+	switch {
+	case s.State == "running" && s.Health != "":
+		return s.Health
+	case s.State == "exited":
+		return fmt.Sprintf("exited (%d)", s.ExitCode)
+	}
+	return s.State
+}
+
+// ParseServiceStatus reads `docker compose ps --format json`, which is a JSON
+// array in older compose versions and a JSON object per line in newer ones
+func ParseServiceStatus(out []byte) ([]ServiceStatus, error) {
+	out = bytes.TrimSpace(out)
+	if len(out) == 0 {
+		return nil, nil
+	}
+
+	statuses := []ServiceStatus{}
+	if out[0] == '[' {
+		if err := json.Unmarshal(out, &statuses); err != nil {
+			return nil, err
+		}
+		return statuses, nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var status ServiceStatus
+		if err := json.Unmarshal(line, &status); err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, scanner.Err()
+}
@@ -0,0 +1,135 @@
+package common
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+)
+
+const (
+	// top-level key listing other config files (globs) to pull workflows from
+	IncludeKey = "include"
+)
+
+// includedWorkflow is a workflow from an included file, namespace is the
+// name of the directory the file is in
+type includedWorkflow struct {
+	workflow  *Workflow
+	namespace string
+	file      string
+}
+
+// loadIncludes adds the workflows of every file matched by the include globs.
+// Each file is loaded on its own (its paths, [defaults] and extends only apply
+// to it), and workflows whose names clash are prefixed with their directory,
+// like billing/api.
+func (c *Config) loadIncludes(loading map[string]bool) error {
+	root := filepath.Dir(c.File)
+
+	seen := map[string]bool{}
+	found := []includedWorkflow{}
+	for _, pattern := range c.include {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(root, pattern)
+		}
+		files, err := filepath.Glob(pattern)
+		if err != nil {
+			return &ConfigError{Key: IncludeKey, Msg: fmt.Sprintf("invalid pattern %s: %+v", pattern, err)}
+		}
+		if len(files) == 0 {
+			c.Warnings = append(c.Warnings, fmt.Sprintf("include %s didn't match any files", c.relative(pattern)))
+		}
+
+		for _, file := range files {
+			if seen[file] {
+				continue
+			}
+			seen[file] = true
+
+			if loading[file] {
+				return &ConfigError{Key: IncludeKey, Msg: fmt.Sprintf("%s is already being loaded (include cycle)", c.relative(file))}
+			}
+			inc, err := loadConfig(file, loading)
+			if err != nil {
+				return fmt.Errorf("%s: %w", c.relative(file), err)
+			}
+
+			for _, warning := range inc.Warnings {
+				c.Warnings = append(c.Warnings, fmt.Sprintf("%s: %s", c.relative(file), warning))
+			}
+			c.Includes = append(c.Includes, inc.File)
+			c.Includes = append(c.Includes, inc.Includes...)
+			for _, name := range inc.Names() {
+				found = append(found, includedWorkflow{
+					workflow:  inc.Workflows[name],
+					namespace: filepath.Base(filepath.Dir(inc.File)),
+					file:      inc.File,
+				})
+			}
+		}
+	}
+
+	// only names used more than once get a namespace
+	counts := map[string]int{}
+	for name := range c.Workflows {
+		counts[name]++
+	}
+	for _, inc := range found {
+		counts[inc.workflow.Name]++
+	}
+
+	sort.SliceStable(found, func(i, j int) bool { return found[i].file < found[j].file })
+	for _, inc := range found {
+		name := inc.workflow.Name
+		if counts[name] > 1 {
+			name = inc.namespace + "/" + name
+		}
+		if _, ok := c.Workflows[name]; ok {
+			return &ConfigError{
+				Workflow: name,
+				Key:      IncludeKey,
+				Msg:      fmt.Sprintf("workflow from %s clashes with another workflow of the same name", c.relative(inc.file)),
+			}
+		}
+
+		inc.workflow.rename(name)
+		inc.workflow.wrapErr(c.relative(inc.file))
+		c.Workflows[name] = inc.workflow
+	}
+
+	return nil
+}
+
+// Files returns the config file followed by every file it includes
+func (c *Config) Files() []string {
+	return append([]string{c.File}, c.Includes...)
+}
+
+// relative shortens a path to be relative to the config file's directory
+func (c *Config) relative(path string) string {
+	if rel, err := filepath.Rel(filepath.Dir(c.File), path); err == nil {
+		return rel
+	}
+	return path
+}
+
+// wrapErr prefixes the variable errors of a workflow and its profiles with
+// the included file they come from
+func (wf *Workflow) wrapErr(file string) {
+	if wf.err != nil {
+		wf.err = fmt.Errorf("%s: %w", file, wf.err)
+	}
+	for _, variant := range wf.variants {
+		if variant.err != nil {
+			variant.err = fmt.Errorf("%s: %w", file, variant.err)
+		}
+	}
+}
+
+// rename changes the name of a workflow and its profiles
+func (wf *Workflow) rename(name string) {
+	wf.Name = name
+	for _, variant := range wf.variants {
+		variant.Name = name
+	}
+}
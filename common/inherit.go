@@ -0,0 +1,243 @@
+package common
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+const (
+	// top-level table whose keys apply to every workflow
+	DefaultsTable = "defaults"
+
+	// how inherited lists (watch, ignore, build) are combined
+	MergeAppend  = "append"
+	MergeReplace = "replace"
+)
+
+var (
+	// keys that belong to a single workflow and are never inherited
+	ownKeys = map[string]bool{
+		"description": true,
+		"extends":     true,
+	}
+)
+
+type resolver struct {
+	parsed   map[string]*Workflow
+	resolved map[string]*Workflow
+	// workflows being resolved, used to catch `extends` cycles
+	visiting map[string]bool
+	lines    []string
+	defaults *Workflow
+}
+
+// resolveWorkflows applies [defaults], `extends` and variables to every workflow
+func resolveWorkflows(parsed map[string]*Workflow, lines []string, root string) (*Config, error) {
+	r := &resolver{
+		parsed:   parsed,
+		resolved: map[string]*Workflow{},
+		visiting: map[string]bool{},
+		lines:    lines,
+		defaults: newWorkflow(""),
+	}
+
+	if defaults, ok := parsed[DefaultsTable]; ok {
+		if defaults.Extends != "" {
+			return nil, &ConfigError{
+				Workflow: DefaultsTable,
+				Key:      "extends",
+				Line:     keyLine(lines, DefaultsTable, "extends"),
+				Msg:      "the defaults table can't extend another workflow",
+			}
+		}
+		r.defaults = mergeWorkflows(r.defaults, defaults)
+		delete(parsed, DefaultsTable)
+	}
+
+	// workflows other workflows extend are allowed to be incomplete
+	templates := map[string]bool{}
+	for _, wf := range parsed {
+		templates[wf.Extends] = true
+	}
+
+	for _, name := range sortedNames(parsed) {
+		if _, err := r.resolve(name); err != nil {
+			return nil, err
+		}
+	}
+
+	// profiles overlay the resolved workflow (inherited profiles included)
+	for _, name := range sortedNames(parsed) {
+		wf := r.resolved[name]
+		wf.variants = map[string]*Workflow{}
+		for _, profile := range sortedNames(wf.Profiles) {
+			variant := mergeWorkflows(wf, wf.Profiles[profile])
+			variant.Name, variant.Description, variant.Extends = name, wf.Description, wf.Extends
+			variant.Profile, variant.Profiles = profile, nil
+			wf.variants[profile] = variant
+		}
+	}
+
+	// variables are expanded once everything is inherited, so ${RELOAD_WORKFLOW}
+	// is the name of the workflow using it
+	conf := &Config{Workflows: map[string]*Workflow{}}
+	for _, name := range sortedNames(parsed) {
+		wf := r.resolved[name]
+		workflows := []*Workflow{wf}
+		for _, profile := range sortedNames(wf.variants) {
+			workflows = append(workflows, wf.variants[profile])
+		}
+		for _, w := range workflows {
+			// an undefined variable only breaks the workflow (or profile) using it
+			w.err = w.interpolate(root, lines)
+			if err := w.validate(lines); err != nil {
+				return nil, err
+			}
+			w.template = templates[name]
+		}
+		conf.Workflows[name] = wf
+	}
+
+	return conf, nil
+}
+
+func (r *resolver) resolve(name string) (*Workflow, error) {
+	if wf, ok := r.resolved[name]; ok {
+		return wf, nil
+	}
+
+	wf := r.parsed[name]
+	parent := r.defaults
+	if wf.Extends != "" {
+		if _, ok := r.parsed[wf.Extends]; !ok {
+			return nil, &ConfigError{
+				Workflow: name,
+				Key:      "extends",
+				Line:     keyLine(r.lines, name, "extends"),
+				Msg:      fmt.Sprintf("could not find workflow %s", wf.Extends),
+			}
+		}
+		if r.visiting[name] {
+			return nil, &ConfigError{
+				Workflow: name,
+				Key:      "extends",
+				Line:     keyLine(r.lines, name, "extends"),
+				Msg:      fmt.Sprintf("%s extends itself (through %s)", name, r.cycle()),
+			}
+		}
+
+		r.visiting[name] = true
+		var err error
+		if parent, err = r.resolve(wf.Extends); err != nil {
+			return nil, err
+		}
+		delete(r.visiting, name)
+	}
+
+	merged := mergeWorkflows(parent, wf)
+	r.resolved[name] = merged
+	return merged, nil
+}
+
+func (r *resolver) cycle() string {
+	names := make([]string, 0, len(r.visiting))
+	for name := range r.visiting {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// mergeWorkflows overlays the keys set in child on top of parent. Lists (and
+// tables like env) are appended to the parent's or replace them depending on
+// the merge key.
+func mergeWorkflows(parent, child *Workflow) *Workflow {
+	out := &Workflow{
+		Name:        child.Name,
+		Description: child.Description,
+		Extends:     child.Extends,
+		defined:     map[string]bool{},
+	}
+	for key := range parent.defined {
+		if !ownKeys[key] {
+			out.defined[key] = true
+		}
+	}
+	for key := range child.defined {
+		out.defined[key] = true
+	}
+
+	strategy := parent.Merge
+	if child.defined["merge"] {
+		strategy = child.Merge
+	}
+
+	pv := reflect.ValueOf(parent).Elem()
+	cv := reflect.ValueOf(child).Elem()
+	ov := reflect.ValueOf(out).Elem()
+	t := ov.Type()
+	for i := 0; i < t.NumField(); i++ {
+		key := tomlKey(t.Field(i))
+		if key == "" || key == "-" || ownKeys[key] {
+			continue
+		}
+
+		field := pv.Field(i)
+		if child.defined[key] {
+			field = cv.Field(i)
+			if field.Kind() == reflect.Slice && strategy == MergeAppend {
+				field = reflect.AppendSlice(copySlice(pv.Field(i)), field)
+			}
+			if field.Kind() == reflect.Map && strategy == MergeAppend {
+				field = mergeMaps(pv.Field(i), field)
+			}
+		}
+		if field.Kind() == reflect.Slice {
+			field = copySlice(field)
+		}
+		if field.Kind() == reflect.Map {
+			field = mergeMaps(field)
+		}
+		ov.Field(i).Set(field)
+	}
+
+	return out
+}
+
+func copySlice(v reflect.Value) reflect.Value {
+	out := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+	reflect.Copy(out, v)
+	return out
+}
+
+// mergeMaps copies maps into a new one, later maps win
+func mergeMaps(maps ...reflect.Value) reflect.Value {
+	out := reflect.MakeMap(maps[0].Type())
+	for _, m := range maps {
+		iter := m.MapRange()
+		for iter.Next() {
+			out.SetMapIndex(iter.Key(), iter.Value())
+		}
+	}
+	return out
+}
+
+func sortedServices(services map[string]ComposeService) []string {
+	names := make([]string, 0, len(services))
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sortedNames(workflows map[string]*Workflow) []string {
+	names := make([]string, 0, len(workflows))
+	for name := range workflows {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,321 @@
+package common
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestInheritance(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		profile string
+		watch   []string
+		build   []string
+		env     map[string]string
+	}{
+		{
+			name: "lists are appended",
+			config: `
+[defaults]
+watch = ["lib"]
+
+[base]
+watch = ["src"]
+build = ["make"]
+
+[api]
+extends = "base"
+watch = ["api"]
+run = "./api"
+`,
+			watch: []string{"lib", "src", "api"},
+			build: []string{"make"},
+		},
+		{
+			name: "merge replace only replaces the lists it sets",
+			config: `
+[defaults]
+watch = ["lib"]
+
+[base]
+watch = ["src"]
+build = ["make"]
+
+[api]
+extends = "base"
+merge = "replace"
+watch = ["api"]
+run = "./api"
+`,
+			watch: []string{"api"},
+			build: []string{"make"},
+		},
+		{
+			name: "merge replace in defaults applies to every workflow",
+			config: `
+[defaults]
+merge = "replace"
+watch = ["lib"]
+
+[api]
+watch = ["api"]
+run = "./api"
+`,
+			watch: []string{"api"},
+			build: []string{},
+		},
+		{
+			name: "env tables are merged, later keys win",
+			config: `
+[defaults]
+env = { A = "defaults", B = "defaults" }
+
+[api]
+run = "./api"
+env = { B = "api", C = "api" }
+`,
+			watch: []string{},
+			build: []string{},
+			env:   map[string]string{"A": "defaults", "B": "api", "C": "api"},
+		},
+		{
+			name: "env tables are replaced with merge replace",
+			config: `
+[defaults]
+env = { A = "defaults", B = "defaults" }
+
+[api]
+run = "./api"
+merge = "replace"
+env = { B = "api" }
+`,
+			watch: []string{},
+			build: []string{},
+			env:   map[string]string{"B": "api"},
+		},
+		{
+			name: "profiles overlay the inherited workflow",
+			config: `
+[base]
+watch = ["src"]
+build = ["make"]
+
+[api]
+extends = "base"
+run = "./api"
+
+[api.profiles.test]
+watch = ["test"]
+env = { MODE = "test" }
+`,
+			profile: "test",
+			watch:   []string{"src", "test"},
+			build:   []string{"make"},
+			env:     map[string]string{"MODE": "test"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf, err := ParseConfig([]byte(tt.config), t.TempDir())
+			if err != nil {
+				t.Fatalf("ParseConfig() error: %v", err)
+			}
+			wf, err := conf.Workflow("api", tt.profile)
+			if err != nil {
+				t.Fatalf("Workflow(api, %q) error: %v", tt.profile, err)
+			}
+			if !reflect.DeepEqual(wf.Watch, tt.watch) {
+				t.Errorf("watch = %q, want %q", wf.Watch, tt.watch)
+			}
+			if !reflect.DeepEqual(wf.Build, tt.build) {
+				t.Errorf("build = %q, want %q", wf.Build, tt.build)
+			}
+			if tt.env == nil {
+				tt.env = map[string]string{}
+			}
+			if !reflect.DeepEqual(wf.Env, tt.env) {
+				t.Errorf("env = %v, want %v", wf.Env, tt.env)
+			}
+		})
+	}
+}
+
+func TestInheritanceErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		want   string
+	}{
+		{
+			name: "cycle",
+			config: `
+[a]
+extends = "b"
+
+[b]
+extends = "a"
+`,
+			want: "line 3: workflow a: key extends: a extends itself (through a, b)",
+		},
+		{
+			name: "extends itself",
+			config: `
+[a]
+extends = "a"
+`,
+			want: "line 3: workflow a: key extends: a extends itself (through a)",
+		},
+		{
+			name: "unknown workflow",
+			config: `
+[a]
+extends = "nope"
+`,
+			want: "line 3: workflow a: key extends: could not find workflow nope",
+		},
+		{
+			name: "defaults extends",
+			config: `
+[defaults]
+extends = "a"
+
+[a]
+run = "./a"
+`,
+			want: "line 3: workflow defaults: key extends: the defaults table can't extend another workflow",
+		},
+		{
+			name: "unknown merge strategy",
+			config: `
+[a]
+run = "./a"
+merge = "prepend"
+`,
+			want: `line 4: workflow a: key merge: expected "append" or "replace", got "prepend"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseConfig([]byte(tt.config), t.TempDir())
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("ParseConfig() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestTemplateWorkflowsSkipBuildRunCheck(t *testing.T) {
+	config := `
+[base]
+ignore = ["tmp"]
+
+[api]
+extends = "base"
+run = "echo api"
+
+[lonely]
+ignore = ["tmp"]
+`
+	conf, err := ParseConfig([]byte(config), t.TempDir())
+	if err != nil {
+		t.Fatalf("ParseConfig() error: %v", err)
+	}
+
+	for name, want := range map[string]int{"base": 0, "api": 0, "lonely": 1} {
+		wf, err := conf.Workflow(name, "")
+		if err != nil {
+			t.Fatalf("Workflow(%s) error: %v", name, err)
+		}
+		if errs := wf.Check(); len(errs) != want {
+			t.Errorf("Workflow(%s).Check() = %v, want %d errors", name, errs, want)
+		}
+	}
+}
+
+// writeConfigs writes config files (relative paths) into a new directory
+func writeConfigs(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		file := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestIncludeNamespacesClashingNames(t *testing.T) {
+	dir := writeConfigs(t, map[string]string{
+		ConfigFile: `
+include = ["*/reload.toml"]
+
+[api]
+run = "echo root"
+`,
+		"billing/reload.toml": `
+[api]
+run = "echo billing"
+
+[worker]
+run = "echo worker"
+`,
+	})
+
+	conf, err := LoadConfig(filepath.Join(dir, ConfigFile))
+	if err != nil {
+		t.Fatalf("LoadConfig() error: %v", err)
+	}
+	want := []string{"api", "billing/api", "worker"}
+	if names := conf.Names(); !reflect.DeepEqual(names, want) {
+		t.Errorf("Names() = %q, want %q", names, want)
+	}
+
+	for name, run := range map[string]string{"api": "echo root", "billing/api": "echo billing", "worker": "echo worker"} {
+		wf, err := conf.Workflow(name, "")
+		if err != nil {
+			t.Fatalf("Workflow(%s) error: %v", name, err)
+		}
+		if wf.Run != run {
+			t.Errorf("Workflow(%s).Run = %q, want %q", name, wf.Run, run)
+		}
+	}
+	// paths stay relative to the included file
+	if wf, _ := conf.Workflow("billing/api", ""); wf.Path != filepath.Join(dir, "billing") {
+		t.Errorf("Workflow(billing/api).Path = %s, want %s", wf.Path, filepath.Join(dir, "billing"))
+	}
+}
+
+func TestIncludeClashingNamespaces(t *testing.T) {
+	dir := writeConfigs(t, map[string]string{
+		ConfigFile: `
+include = ["*/billing/reload.toml"]
+
+[api]
+run = "echo root"
+`,
+		"eu/billing/reload.toml": `
+[api]
+run = "echo eu"
+`,
+		"us/billing/reload.toml": `
+[api]
+run = "echo us"
+`,
+	})
+
+	_, err := LoadConfig(filepath.Join(dir, ConfigFile))
+	want := "workflow billing/api: key include: workflow from us/billing/reload.toml clashes with another workflow of the same name"
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("LoadConfig() error = %v, want %q", err, want)
+	}
+}
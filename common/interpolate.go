@@ -0,0 +1,140 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	// built-in variables, these win over the environment
+	VarWorkflow  = "RELOAD_WORKFLOW"
+	VarRoot      = "RELOAD_ROOT"
+	VarGitBranch = "RELOAD_GIT_BRANCH"
+	VarProfile   = "RELOAD_PROFILE"
+)
+
+var (
+	// ${VAR}, ${VAR:-default} and $$ (a literal $)
+	varPattern = regexp.MustCompile(`\$\$|\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+)
+
+// Interpolate expands ${VAR} and ${VAR:-default} using the builtin variables
+// and the environment. ${VAR} must be set (empty is fine, builtins always
+// are), ${VAR:-default} falls back when VAR is unset or empty.
+func Interpolate(s string, builtins map[string]string) (string, error) {
+	var err error
+	out := varPattern.ReplaceAllStringFunc(s, func(match string) string {
+		if match == "$$" {
+			return "$"
+		}
+
+		groups := varPattern.FindStringSubmatch(match)
+		name, hasDefault, def := groups[1], groups[2] != "", groups[3]
+		val, ok := builtins[name]
+		if !ok {
+			val, ok = os.LookupEnv(name)
+		}
+		if hasDefault && val == "" {
+			return def
+		}
+		if ok {
+			return val
+		}
+
+		if err == nil {
+			err = fmt.Errorf("undefined variable %s (use ${%s:-default} to make it optional)", name, name)
+		}
+		return match
+	})
+
+	return out, err
+}
+
+// interpolate expands variables in a workflow's path, watch, ignore, build,
+// run, service, compose_file, project, image, container and volumes keys and resolves its path against the config's directory
+func (wf *Workflow) interpolate(root string, lines []string) error {
+	builtins := map[string]string{
+		VarWorkflow:  wf.Name,
+		VarRoot:      root,
+		VarGitBranch: GitBranch(root),
+		VarProfile:   wf.Profile,
+	}
+
+	expand := func(key string, s *string) error {
+		val, err := Interpolate(*s, builtins)
+		if err != nil {
+			return &ConfigError{
+				Workflow: wf.Name,
+				Key:      key,
+				Line:     keyLine(lines, wf.Name, key),
+				Msg:      err.Error(),
+			}
+		}
+		*s = val
+		return nil
+	}
+	expandAll := func(key string, list []string) error {
+		for i := range list {
+			if err := expand(key, &list[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := expand("path", &wf.Path); err != nil {
+		return err
+	}
+	if !filepath.IsAbs(wf.Path) {
+		wf.Path = filepath.Join(root, wf.Path)
+	}
+	if err := expandAll("watch", wf.Watch); err != nil {
+		return err
+	}
+	if err := expandAll("ignore", wf.Ignore); err != nil {
+		return err
+	}
+	if err := expandAll("build", wf.Build); err != nil {
+		return err
+	}
+	if err := expand("run", &wf.Run); err != nil {
+		return err
+	}
+	if err := expand("service", &wf.Service); err != nil {
+		return err
+	}
+	if err := expandAll("compose_file", wf.ComposeFile); err != nil {
+		return err
+	}
+	if err := expand("project", &wf.Project); err != nil {
+		return err
+	}
+	if err := expand("image", &wf.Image); err != nil {
+		return err
+	}
+	if err := expand("container", &wf.Container); err != nil {
+		return err
+	}
+	return expandAll("volumes", wf.Volumes)
+}
+
+// GitBranch returns the checked out branch (or the short commit hash in
+// detached HEAD state) of the repository containing path
+func GitBranch(path string) string {
+	g := NewGitGuard(path)
+	if g == nil {
+		return ""
+	}
+
+	head := g.readHead()
+	if strings.HasPrefix(head, "ref:") {
+		return strings.TrimPrefix(strings.TrimSpace(strings.TrimPrefix(head, "ref:")), "refs/heads/")
+	}
+	if len(head) > 7 {
+		return head[:7]
+	}
+	return head
+}
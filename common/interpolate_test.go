@@ -0,0 +1,106 @@
+package common
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInterpolate(t *testing.T) {
+	os.Setenv("RELOAD_TEST_SET", "value")
+	os.Setenv("RELOAD_TEST_EMPTY", "")
+	os.Unsetenv("RELOAD_TEST_UNSET")
+	defer os.Unsetenv("RELOAD_TEST_SET")
+	defer os.Unsetenv("RELOAD_TEST_EMPTY")
+
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: "${RELOAD_TEST_SET}", want: "value"},
+		{in: "${RELOAD_TEST_SET:-default}", want: "value"},
+		// set but empty is defined, only :- falls back on it
+		{in: "a${RELOAD_TEST_EMPTY}b", want: "ab"},
+		{in: "${RELOAD_TEST_EMPTY:-default}", want: "default"},
+		{in: "${RELOAD_TEST_UNSET:-default}", want: "default"},
+		{in: "${RELOAD_TEST_UNSET}", wantErr: true},
+		{in: "$$HOME", want: "$HOME"},
+	}
+
+	for _, tt := range tests {
+		got, err := Interpolate(tt.in, nil)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Interpolate(%q) = %q, want an error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("Interpolate(%q) = %q, %v, want %q", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestBuiltinVariablesCanBeEmpty(t *testing.T) {
+	// outside a git repository without a profile, both builtins are empty
+	dir := t.TempDir()
+	file := filepath.Join(dir, ConfigFile)
+	config := `
+[web]
+run = "echo [${RELOAD_PROFILE}] [${RELOAD_GIT_BRANCH}]"
+
+[web.profiles.dev]
+verbose = false
+`
+	if err := ioutil.WriteFile(file, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	conf, err := LoadConfig(file)
+	if err != nil {
+		t.Fatalf("LoadConfig() error: %v", err)
+	}
+	for profile, want := range map[string]string{"": "echo [] []", "dev": "echo [dev] []"} {
+		wf, err := conf.Workflow("web", profile)
+		if err != nil {
+			t.Fatalf("Workflow(web, %q) error: %v", profile, err)
+		}
+		if wf.Run != want {
+			t.Errorf("Workflow(web, %q).Run = %q, want %q", profile, wf.Run, want)
+		}
+	}
+}
+
+func TestUndefinedVariableOnlyBreaksItsWorkflow(t *testing.T) {
+	os.Unsetenv("RELOAD_TEST_UNSET")
+	file := filepath.Join(t.TempDir(), ConfigFile)
+	config := `
+[web]
+run = "echo web"
+
+[web.profiles.secret]
+run = "echo ${RELOAD_TEST_UNSET}"
+
+[api]
+run = "echo ${RELOAD_TEST_UNSET}"
+`
+	if err := ioutil.WriteFile(file, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	conf, err := LoadConfig(file)
+	if err != nil {
+		t.Fatalf("LoadConfig() error: %v", err)
+	}
+	if _, err := conf.Workflow("web", ""); err != nil {
+		t.Errorf("Workflow(web) error: %v", err)
+	}
+	for _, wf := range [][2]string{{"web", "secret"}, {"api", ""}} {
+		if _, err := conf.Workflow(wf[0], wf[1]); err == nil || !strings.Contains(err.Error(), "undefined variable RELOAD_TEST_UNSET") {
+			t.Errorf("Workflow(%s, %q) error = %v, want an undefined variable", wf[0], wf[1], err)
+		}
+	}
+}
@@ -16,5 +16,7 @@ var (
 	ExtraHiGreen  = color.New(color.Bold, color.Underline, color.FgHiGreen).SprintFunc()
 	Mgnta         = color.New(color.FgMagenta).SprintFunc()
 	LogEvent      = func(format, event string) { log.Println(color.MagentaString(format, event)) }
-	BasicLogError = func(msg string) { log.Fatalf("%s\t%s", ErrorRed("error"), msg) }
+	BasicLogError = func(msg string) { log.Printf("%s\t%s", ErrorRed("error"), msg); Exit(1) }
+	LogError      = func(msg string) { log.Printf("%s\t%s", ErrorRed("error"), msg) }
+	LogWarning    = func(msg string) { log.Printf("%s\t%s", Ylw("warning"), msg) }
 )
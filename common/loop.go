@@ -0,0 +1,99 @@
+package common
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Loop is the file event loop shared by every workflow type. The workflow
+// decides what a change means through the callbacks.
+type Loop struct {
+	Watcher  *fsnotify.Watcher
+	WC       *WatcherConfig
+	EnvFiles []string
+
+	// Track is called for every change (even while git is busy) and reports
+	// whether it needs a reload. A nil Track reloads on every change.
+	Track func(name string, isEnvFile bool) bool
+	// Reload reruns the workflow after changes
+	Reload func()
+	// Stop cleans up once the stop channel is closed
+	Stop func()
+}
+
+// Run handles file events until stop is closed (a nil stop channel runs
+// forever). Reloads are held off while git is rewriting the working tree.
+func (l *Loop) Run(stop <-chan struct{}) {
+	guard := NewGitGuard(l.WC.Path)
+	ticker := time.NewTicker(GitPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case event, ok := <-l.Watcher.Events:
+			if !ok {
+				BasicLogError("failed to read from watcher.Events channel")
+			}
+			l.handle(event, guard)
+		case <-stop:
+			// the workflow changed or reload is shutting down
+			l.Stop()
+			return
+		case <-ticker.C:
+			if n, ok := guard.Release(); ok {
+				LogEvent("git operation finished, reloading with %s", fmt.Sprintf("%d changes", n))
+				l.Reload()
+			}
+		case err, ok := <-l.Watcher.Errors:
+			if !ok {
+				BasicLogError("failed to read from watcher.Errors channel")
+			}
+			BasicLogError(fmt.Sprintf("%+v", err))
+		}
+	}
+}
+
+func (l *Loop) handle(event fsnotify.Event, guard *GitGuard) {
+	event.Name = filepath.Clean(event.Name)
+
+	// env files are excluded from watching by default, but still restart the workflow
+	isEnvFile := IsEnvFile(event.Name, l.WC.Path, l.EnvFiles)
+	if !isEnvFile && (l.WC.Excludes(event.Name) || l.WC.envOnly(event.Name)) {
+		return
+	}
+
+	changed := event.Op&fsnotify.Chmod != fsnotify.Chmod
+	created := event.Op&fsnotify.Create == fsnotify.Create
+	reload := changed && (l.Track == nil || l.Track(event.Name, isEnvFile))
+	if !reload && !created {
+		return
+	}
+
+	// new files are watched right away, even when the reload waits for git
+	if created {
+		l.WC.Watch = append(l.WC.Watch, event.Name)
+		AddToFileWatcher(l.Watcher, l.WC)
+	}
+	if changed && guard.Hold() {
+		return
+	}
+
+	if created {
+		LogEvent("a wild %s has appeared", event.Name)
+	} else if event.Op&fsnotify.Remove == fsnotify.Remove {
+		LogEvent("%s has been removed", event.Name)
+		// todo: handle removing from file watcher
+	} else if event.Op&fsnotify.Rename == fsnotify.Rename {
+		LogEvent("%s has a new name", event.Name)
+	} else if event.Op&fsnotify.Write == fsnotify.Write && isEnvFile {
+		LogEvent("🌱 %s has changed, reloading environment", event.Name)
+	} else if event.Op&fsnotify.Write == fsnotify.Write {
+		LogEvent("%s has changed", event.Name)
+	}
+	if reload {
+		l.Reload()
+	}
+}
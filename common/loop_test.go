@@ -0,0 +1,65 @@
+package common
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+func TestLoopReloadsOnEnvFilesCreatedLater(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, "src"), 0755)
+
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	// .env doesn't exist yet and only src is watched
+	wc := &WatcherConfig{Path: dir, Watch: []string{"src"}, Ignore: []string{}}
+	envFiles := []string{".env"}
+	if err := AddToFileWatcher(w, wc); err != nil {
+		t.Fatal(err)
+	}
+	WatchEnvFiles(w, wc, envFiles)
+
+	reloads := make(chan string, 10)
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	loop := &Loop{
+		Watcher:  w,
+		WC:       wc,
+		EnvFiles: envFiles,
+		Track: func(name string, isEnvFile bool) bool {
+			reloads <- filepath.Base(name)
+			return true
+		},
+		Reload: func() {},
+		Stop:   func() {},
+	}
+	go func() {
+		loop.Run(stop)
+		close(done)
+	}()
+	defer func() {
+		close(stop)
+		<-done
+	}()
+
+	// other files next to the env file don't reload the workflow
+	ioutil.WriteFile(filepath.Join(dir, "notes.md"), []byte("notes"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, ".env"), []byte("A=1"), 0644)
+	select {
+	case name := <-reloads:
+		if name != ".env" {
+			t.Fatalf("reloaded for %s, want .env", name)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("creating .env didn't reload the workflow")
+	}
+}
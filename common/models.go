@@ -1,24 +1,78 @@
 package common
 
+import "time"
+
 type WatcherConfig struct {
 	Path   string
 	Watch  []string
 	Ignore []string
+	// directories that are only watched for the env files in them
+	envDirs map[string]bool
+}
+
+// ComposeService is a compose service that is restarted on its own when the
+// files it watches change (globs relative to the workflow's path)
+type ComposeService struct {
+	Watch  []string `toml:"watch" json:"watch" yaml:"watch"`
+	Ignore []string `toml:"ignore" json:"ignore" yaml:"ignore"`
+	// local paths (relative to the workflow's path) copied into the running
+	// container instead of restarting it, mapped to their path in the container
+	Sync map[string]string `toml:"sync,omitempty" json:"sync,omitempty" yaml:"sync,omitempty"`
+	// runs in the container after syncing, like "kill -HUP 1"
+	SyncCommand string `toml:"sync_command,omitempty" json:"sync_command,omitempty" yaml:"sync_command,omitempty"`
 }
 
 // Docker flags
 type ComposeFlags struct {
-	WC      WatcherConfig
-	Service string // only if Compose is true
-	Run     string
-	Clean   string
-	Verbose bool
+	WC WatcherConfig
+	// services to run, every service in the project if empty
+	Services map[string]ComposeService
+	// rebuild images before (re)starting services
+	Rebuild   bool
+	BuildArgs map[string]string
+	NoCache   bool
+	// docker compose, podman compose, etc. (detected when empty)
+	Engine string
+	// docker compose options: -f, -p, --profile & --env-file
+	Files           []string
+	Project         string
+	Profiles        []string
+	ComposeEnvFiles []string
+	// stop, down, down-volumes or none when reload exits
+	OnExit string
+	// how long to wait for the services to be running & healthy, 0 doesn't wait
+	WaitTimeout time.Duration
+	Run         []string
+	Clean       []string
+	Verbose     bool
+	Env         map[string]string
+	EnvFiles    []string
+}
+
+// Dockerfile flags (a single container without compose)
+type DockerFlags struct {
+	WC WatcherConfig
+	// workflow (or directory) name, containers are labeled with it
+	Name       string
+	Engine     string
+	Dockerfile string
+	Image      string
+	Container  string
+	Ports      []string
+	Volumes    []string
+	BuildArgs  map[string]string
+	NoCache    bool
+	Verbose    bool
+	Env        map[string]string
+	EnvFiles   []string
 }
 
 // Basic Flags
 type RootFlags struct {
-	WC      WatcherConfig
-	Build   []string
-	Run     string
-	Verbose bool
+	WC       WatcherConfig
+	Build    []string
+	Run      string
+	Verbose  bool
+	Env      map[string]string
+	EnvFiles []string
 }
@@ -0,0 +1,136 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Check looks for problems that only show up once a workflow runs: missing
+// paths, commands that aren't on PATH and ignore globs that don't compile
+func (wf *Workflow) Check() []error {
+	var errs []error
+	fail := func(key, format string, args ...interface{}) {
+		errs = append(errs, &ConfigError{
+			Workflow: wf.Name,
+			Key:      key,
+			Msg:      fmt.Sprintf(format, args...),
+		})
+	}
+
+	if info, err := os.Stat(wf.Path); err != nil {
+		fail("path", "%s does not exist", wf.Path)
+	} else if !info.IsDir() {
+		fail("path", "%s is not a directory", wf.Path)
+	}
+
+	for _, file := range wf.Watch {
+		if _, err := os.Stat(filepath.Join(wf.Path, file)); err != nil {
+			fail("watch", "%s does not exist", filepath.Join(wf.Path, file))
+		}
+	}
+	for _, pattern := range wf.Ignore {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			fail("ignore", "invalid pattern %q", pattern)
+		}
+	}
+
+	if wf.Type() == "docker" {
+		if _, err := os.Stat(filepath.Join(wf.Path, wf.Dockerfile)); err != nil {
+			fail("dockerfile", "%s does not exist", filepath.Join(wf.Path, wf.Dockerfile))
+		}
+		if wf.Engine == "" {
+			if _, err := DetectContainerEngine(); err != nil {
+				fail("engine", "%s", err)
+			}
+		} else if _, err := exec.LookPath(wf.Engine); err != nil {
+			fail("engine", "%s is not installed (or not on your PATH)", wf.Engine)
+		}
+		return errs
+	}
+
+	if wf.Containerized {
+		for _, name := range sortedServices(wf.Services) {
+			svc := wf.Services[name]
+			for _, pattern := range append(append([]string{}, svc.Watch...), svc.Ignore...) {
+				if _, err := filepath.Match(pattern, ""); err != nil {
+					fail("services."+name, "invalid pattern %q", pattern)
+				}
+			}
+			for local, target := range svc.Sync {
+				if _, err := os.Stat(filepath.Join(wf.Path, local)); err != nil {
+					fail("services."+name+".sync", "%s does not exist", filepath.Join(wf.Path, local))
+				}
+				if !strings.HasPrefix(target, "/") {
+					fail("services."+name+".sync", "container path %s of %s must be absolute", target, local)
+				}
+			}
+		}
+		for _, file := range wf.ComposeFile {
+			if _, err := os.Stat(filepath.Join(wf.Path, file)); err != nil {
+				fail("compose_file", "%s does not exist", filepath.Join(wf.Path, file))
+			}
+		}
+		for _, file := range wf.ComposeEnvFile {
+			if _, err := os.Stat(filepath.Join(wf.Path, file)); err != nil {
+				fail("compose_env_file", "%s does not exist", filepath.Join(wf.Path, file))
+			}
+		}
+		if wf.Engine == "" {
+			if _, err := DetectComposeEngine(); err != nil {
+				fail("engine", "%s", err)
+			}
+		} else if !ComposeEngineWorks(wf.Engine) {
+			fail("engine", "%s is not installed (or not on your PATH)", wf.Engine)
+		}
+		return errs
+	}
+
+	// both build and run can't be empty (at the same time), unless the
+	// workflow is only a base for other workflows to extend
+	if !wf.template && len(wf.Build) == 0 && wf.Run == "" {
+		fail("build/run", "at least one build or run command must be set")
+	}
+	for _, cmd := range wf.Build {
+		if err := lookCommand(cmd, wf.Path); err != nil {
+			fail("build", "%s", err)
+		}
+	}
+	// the build can create the run program (go build -o tmp/app .), so it may
+	// not exist yet
+	if wf.Run != "" && (len(wf.Build) == 0 || !isRelativeProgram(wf.Run)) {
+		if err := lookCommand(wf.Run, wf.Path); err != nil {
+			fail("run", "%s", err)
+		}
+	}
+
+	return errs
+}
+
+// isRelativeProgram reports whether a command runs a program by its relative
+// path (./tmp/app) instead of looking it up on PATH
+func isRelativeProgram(cmd string) bool {
+	fields := strings.Fields(cmd)
+	return len(fields) > 0 && strings.ContainsRune(fields[0], filepath.Separator) && !filepath.IsAbs(fields[0])
+}
+
+// lookCommand makes sure the program of a shell command can be executed
+func lookCommand(cmd, dir string) error {
+	fields := strings.Fields(cmd)
+	if len(fields) == 0 {
+		return fmt.Errorf("empty command")
+	}
+
+	prog := fields[0]
+	if isRelativeProgram(cmd) {
+		// relative programs are resolved from the workflow's path
+		prog = filepath.Join(dir, prog)
+	}
+	if _, err := exec.LookPath(prog); err != nil {
+		return fmt.Errorf("%s is not executable or not on your PATH", fields[0])
+	}
+
+	return nil
+}
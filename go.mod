@@ -8,4 +8,5 @@ require (
 	github.com/fsnotify/fsnotify v1.5.4
 	github.com/spf13/cobra v1.5.0
 	github.com/spf13/pflag v1.0.5
+	gopkg.in/yaml.v3 v3.0.1
 )